@@ -0,0 +1,140 @@
+package binlog
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/ace3/mysql-aes"
+	"github.com/ace3/mysql-aes/keyring"
+)
+
+const keyID = "MySQLReplicationKey_9b9fb5e3-3fb3-11ee-9b4a-0242ac110002_1"
+
+// encryptBinlog encrypts a plain binlog in the layout the server writes. No binlog written by a
+// server with binlog_encryption=ON is included; TestFileKeyIV_OpenSSL checks the file key
+// derivation against openssl.
+func encryptBinlog(t *testing.T, plain, masterKey, password, iv []byte) []byte {
+	t.Helper()
+	header := make([]byte, HeaderSize)
+	copy(header, EncryptedMagic)
+	header[4] = headerVersion1
+	pos := 5
+	header[pos], header[pos+1] = fieldKeyID, byte(len(keyID))
+	pos += 2 + copy(header[pos+2:], keyID)
+
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header[pos] = fieldFilePassword
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(header[pos+1:pos+1+filePasswordSize], password)
+	pos += 1 + filePasswordSize
+	header[pos] = fieldPasswordIV
+	copy(header[pos+1:], iv)
+
+	key, ctrIV := fileKeyIV(password)
+	fileBlock, _ := aes.NewCipher(key)
+	body := make([]byte, len(plain))
+	cipher.NewCTR(fileBlock, ctrIV).XORKeyStream(body, plain)
+	return append(header, body...)
+}
+
+func samplePlainBinlog() []byte {
+	plain := append([]byte{}, Magic...)
+	for i := 0; i < 1000; i++ {
+		plain = append(plain, byte(i*7))
+	}
+	return plain
+}
+
+func TestNewReader_Encrypted(t *testing.T) {
+	masterKey := bytes.Repeat([]byte{0x5a}, 32)
+	password := bytes.Repeat([]byte{0x3c}, 32)
+	iv := bytes.Repeat([]byte{0x01}, 16)
+	plain := samplePlainBinlog()
+	encrypted := encryptBinlog(t, plain, masterKey, password, iv)
+
+	ks := &keyring.Keyring{Keys: []keyring.Key{{ID: keyID, Type: "AES", Data: masterKey}}}
+	r, err := NewReader(bytes.NewReader(encrypted), ks)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("Decrypted binlog does not match")
+	}
+
+	h, err := ParseHeader(encrypted)
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if h.KeyID != keyID || !bytes.Equal(h.IV, iv) {
+		t.Errorf("Unexpected header %+v", h)
+	}
+	if p, _ := h.FilePassword(masterKey); !bytes.Equal(p, password) {
+		t.Errorf("Expected file password %x, got %x", password, p)
+	}
+
+	wrong := mysql_aes.StaticKeySource{keyID: bytes.Repeat([]byte{0x11}, 32)}
+	if _, err := NewReader(bytes.NewReader(encrypted), wrong); err == nil {
+		t.Error("Expected error for a wrong master key")
+	}
+	if _, err := NewReader(bytes.NewReader(encrypted), mysql_aes.StaticKeySource{}); !errors.Is(err, mysql_aes.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+// TestFileKeyIV_OpenSSL checks the derivation against the EVP_BytesToKey call the server
+// makes, as printed by: openssl enc -aes-256-ctr -md sha512 -nosalt -pass pass:<password> -P
+func TestFileKeyIV_OpenSSL(t *testing.T) {
+	key, iv := fileKeyIV([]byte("0123456789abcdef0123456789abcdef"))
+	if got := hex.EncodeToString(key); got != "0372a8619ebcbbcd91abcda1ba389ac36e72962887b3019de3372a561fd962da" {
+		t.Errorf("Unexpected key %s", got)
+	}
+	if got := hex.EncodeToString(iv); got != "7e9cfa0c3069c46ca5dc2ad88e607e70" {
+		t.Errorf("Unexpected IV %s", got)
+	}
+}
+
+func TestNewReader_Plain(t *testing.T) {
+	plain := samplePlainBinlog()
+	r, err := NewReader(bytes.NewReader(plain), mysql_aes.StaticKeySource{})
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	if !bytes.Equal(got, plain) {
+		t.Error("Expected plain binlog to pass through unchanged")
+	}
+}
+
+func TestParseHeader_Invalid(t *testing.T) {
+	valid := encryptBinlog(t, samplePlainBinlog(), make([]byte, 32), make([]byte, 32), make([]byte, 16))[:HeaderSize]
+
+	tests := []struct {
+		name   string
+		modify func([]byte)
+	}{
+		{"magic", func(h []byte) { h[0] = 0 }},
+		{"version", func(h []byte) { h[4] = 2 }},
+		{"unknown field", func(h []byte) { h[5] = 9 }},
+		{"missing IV", func(h []byte) { h[7+len(keyID)+1+filePasswordSize] = fieldEnd }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := append([]byte{}, valid...)
+			tt.modify(h)
+			if _, err := ParseHeader(h); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package mysql_aes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// DefaultBlindIndexSize is the default length in bytes of a blind index value
+	DefaultBlindIndexSize = 16
+	// MinBlindIndexSize is the shortest truncation allowed; shorter values collide too often to be useful
+	MinBlindIndexSize = 4
+)
+
+// Normalizer canonicalizes a value before it is indexed, so that equivalent spellings match
+type Normalizer func(string) string
+
+var foldCaser = cases.Fold()
+
+// NormalizeTrim removes leading and trailing white space
+func NormalizeTrim(s string) string {
+	return strings.TrimSpace(s)
+}
+
+// NormalizeCaseFold applies Unicode case folding
+func NormalizeCaseFold(s string) string {
+	return foldCaser.String(s)
+}
+
+// NormalizeNFC converts the value to Unicode normalization form C
+func NormalizeNFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// NormalizeEmail canonicalizes an email address: it trims and lower-cases the address and
+// drops a "+tag" sub-address from the local part. Values without an "@" are only trimmed and
+// lower-cased.
+func NormalizeEmail(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return s
+	}
+	local, domain := s[:at], strings.TrimSuffix(s[at+1:], ".")
+	if plus := strings.IndexByte(local, '+'); plus > 0 {
+		local = local[:plus]
+	}
+	return local + "@" + domain
+}
+
+// NormalizePhone canonicalizes a phone number by keeping only its digits.
+// A leading "+" or "00" international prefix is kept as "+".
+func NormalizePhone(s string) string {
+	// NFKC folds full-width and other compatibility digits to ASCII
+	s = strings.TrimSpace(norm.NFKC.String(s))
+	var b strings.Builder
+	if strings.HasPrefix(s, "+") {
+		b.WriteByte('+')
+	} else if strings.HasPrefix(s, "00") {
+		b.WriteByte('+')
+		s = s[2:]
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// BlindIndex computes keyed HMAC-SHA256 values of plaintexts so that encrypted columns can be
+// searched by equality without the deterministic ciphertext. The index key must be separate
+// from the encryption key; use a different key (see DeriveBlindIndexKey) for every column so
+// equal values do not match across columns.
+type BlindIndex struct {
+	key         []byte
+	size        int
+	normalizers []Normalizer
+}
+
+// NewBlindIndex creates a BlindIndex that truncates values to size bytes and applies the
+// normalizers in order before hashing
+func NewBlindIndex(key []byte, size int, normalizers ...Normalizer) (*BlindIndex, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	if size < MinBlindIndexSize || size > sha256.Size {
+		return nil, fmt.Errorf("blind index size must be between %d and %d bytes", MinBlindIndexSize, sha256.Size)
+	}
+	k := make([]byte, len(key))
+	copy(k, key)
+	return &BlindIndex{
+		key:         k,
+		size:        size,
+		normalizers: normalizers,
+	}, nil
+}
+
+// DeriveBlindIndexKey derives a per-column index key from a root index key, so each column
+// gets an independent index without managing separate secrets
+func DeriveBlindIndexKey(rootKey []byte, table, column string) []byte {
+	mac := hmac.New(sha256.New, rootKey)
+	mac.Write([]byte("mysql-aes blind index\x00" + table + "\x00" + column))
+	return mac.Sum(nil)
+}
+
+// Normalize applies the configured normalizers to value
+func (b *BlindIndex) Normalize(value string) string {
+	for _, n := range b.normalizers {
+		value = n(value)
+	}
+	return value
+}
+
+// Compute returns the truncated HMAC of the normalized value
+func (b *BlindIndex) Compute(value string) []byte {
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(b.Normalize(value)))
+	return mac.Sum(nil)[:b.size]
+}
+
+// Index returns the hex blind index value to store alongside the encrypted column on writes
+func (b *BlindIndex) Index(value string) string {
+	return hex.EncodeToString(b.Compute(value))
+}
+
+// Lookup returns a WHERE condition and its arguments that match rows whose index column
+// equals any of the given values, e.g. "`email_bidx` = ?" or "`email_bidx` IN (?, ?)"
+func (b *BlindIndex) Lookup(column string, values ...string) (string, []interface{}) {
+	if len(values) == 0 {
+		// Matches nothing, which is what an empty IN list would mean
+		return "1 = 0", nil
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = b.Index(v)
+	}
+	if len(values) == 1 {
+		return quoteIdentifier(column) + " = ?", args
+	}
+	return quoteIdentifier(column) + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ") + ")", args
+}
+
+// quoteIdentifier quotes a MySQL identifier with backquotes, keeping a qualified name's dots
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = "`" + strings.ReplaceAll(p, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
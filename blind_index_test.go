@@ -0,0 +1,97 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNormalizers(t *testing.T) {
+	testCases := []struct {
+		name       string
+		normalizer Normalizer
+		input      string
+		expected   string
+	}{
+		{"trim", NormalizeTrim, "  hello\t\n", "hello"},
+		{"case fold", NormalizeCaseFold, "Straße ΣΊΣΥΦΟΣ", "strasse σίσυφοσ"},
+		{"nfc", NormalizeNFC, "e\u0301", "\u00e9"},
+		{"email", NormalizeEmail, "  John.Doe+Newsletter@Example.COM ", "john.doe@example.com"},
+		{"email trailing dot", NormalizeEmail, "a@example.com.", "a@example.com"},
+		{"not an email", NormalizeEmail, " Foo ", "foo"},
+		{"phone", NormalizePhone, "+1 (555) 012-3456", "+15550123456"},
+		{"phone 00 prefix", NormalizePhone, "0044 20 7946 0000", "+442079460000"},
+		{"phone full-width", NormalizePhone, "５５５-０１２３", "5550123"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.normalizer(tc.input); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	key := DeriveBlindIndexKey([]byte("index-root-key"), "users", "email")
+	bidx, err := NewBlindIndex(key, 8, NormalizeNFC, NormalizeEmail)
+	if err != nil {
+		t.Fatalf("NewBlindIndex failed: %v", err)
+	}
+
+	a := bidx.Index("Alice+shop@Example.com")
+	b := bidx.Index("alice@example.com")
+	if a != b {
+		t.Errorf("Expected equivalent emails to share an index value, got %q and %q", a, b)
+	}
+	if len(a) != 16 {
+		t.Errorf("Expected 8 byte index as 16 hex chars, got %d", len(a))
+	}
+	if bidx.Index("bob@example.com") == a {
+		t.Error("Expected different values to have different index values")
+	}
+
+	// A different column must not share index values
+	other, _ := NewBlindIndex(DeriveBlindIndexKey([]byte("index-root-key"), "users", "backup_email"), 8, NormalizeEmail)
+	if other.Index("alice@example.com") == a {
+		t.Error("Expected per-column keys to produce different index values")
+	}
+
+	if bytes.Equal(DeriveBlindIndexKey([]byte("k"), "a", "bc"), DeriveBlindIndexKey([]byte("k"), "ab", "c")) {
+		t.Error("Expected table and column to be separated in key derivation")
+	}
+}
+
+func TestBlindIndex_Lookup(t *testing.T) {
+	bidx, err := NewBlindIndex([]byte("index-key"), DefaultBlindIndexSize, NormalizeTrim)
+	if err != nil {
+		t.Fatalf("NewBlindIndex failed: %v", err)
+	}
+
+	query, args := bidx.Lookup("email_bidx", " x ")
+	if query != "`email_bidx` = ?" || len(args) != 1 || args[0] != bidx.Index("x") {
+		t.Errorf("Unexpected single lookup: %q %v", query, args)
+	}
+
+	query, args = bidx.Lookup("u.email_bidx", "x", "y")
+	if query != "`u`.`email_bidx` IN (?, ?)" || len(args) != 2 || args[1] != bidx.Index("y") {
+		t.Errorf("Unexpected multi lookup: %q %v", query, args)
+	}
+
+	query, args = bidx.Lookup("email_bidx")
+	if query != "1 = 0" || args != nil {
+		t.Errorf("Unexpected empty lookup: %q %v", query, args)
+	}
+}
+
+func TestBlindIndex_Errors(t *testing.T) {
+	if _, err := NewBlindIndex(nil, DefaultBlindIndexSize); err == nil {
+		t.Error("Expected error for empty key")
+	}
+	if _, err := NewBlindIndex([]byte("k"), MinBlindIndexSize-1); err == nil {
+		t.Error("Expected error for too short size")
+	}
+	if _, err := NewBlindIndex([]byte("k"), 33); err == nil {
+		t.Error("Expected error for too long size")
+	}
+}
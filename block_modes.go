@@ -0,0 +1,88 @@
+package mysql_aes
+
+import "crypto/cipher"
+
+// cryptBlocks runs a block cipher mode over data: ecb, cbc, cfb1, cfb8, cfb128, ofb or ctr.
+// The ecb and cbc modes need data padded to whole blocks; the others are stream modes. iv is
+// ignored by ecb and must be one block long otherwise.
+func cryptBlocks(block cipher.Block, mode string, iv, data []byte, encrypt bool) []byte {
+	out := make([]byte, len(data))
+	bs := block.BlockSize()
+	switch mode {
+	case "ecb":
+		for i := 0; i < len(data); i += bs {
+			if encrypt {
+				block.Encrypt(out[i:i+bs], data[i:i+bs])
+			} else {
+				block.Decrypt(out[i:i+bs], data[i:i+bs])
+			}
+		}
+	case "cbc":
+		if encrypt {
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+		} else {
+			cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+		}
+	case "cfb1":
+		cfbBits(block, iv, out, data, encrypt)
+	case "cfb8":
+		cfbBytes(block, iv, out, data, 1, encrypt)
+	case "cfb128":
+		cfbBytes(block, iv, out, data, bs, encrypt)
+	case "ofb":
+		cipher.NewOFB(block, iv).XORKeyStream(out, data)
+	case "ctr":
+		cipher.NewCTR(block, iv).XORKeyStream(out, data)
+	default:
+		panic("unknown block mode " + mode)
+	}
+	return out
+}
+
+// cfbBytes runs CFB mode with a feedback segment of segment bytes (CFB8 or CFB128)
+func cfbBytes(block cipher.Block, iv, dst, src []byte, segment int, encrypt bool) {
+	reg := append([]byte{}, iv...)
+	stream := make([]byte, block.BlockSize())
+	for i := 0; i < len(src); i += segment {
+		block.Encrypt(stream, reg)
+		end := i + segment
+		if end > len(src) {
+			end = len(src)
+		}
+		for j := i; j < end; j++ {
+			dst[j] = src[j] ^ stream[j-i]
+		}
+		// The ciphertext is fed back into the register
+		feedback := dst[i:end]
+		if !encrypt {
+			feedback = src[i:end]
+		}
+		reg = append(reg[len(feedback):], feedback...)
+	}
+}
+
+// cfbBits runs CFB mode with 1-bit feedback, most significant bit first like OpenSSL's CFB1
+func cfbBits(block cipher.Block, iv, dst, src []byte, encrypt bool) {
+	reg := append([]byte{}, iv...)
+	stream := make([]byte, block.BlockSize())
+	for i := range src {
+		var out byte
+		for bit := 7; bit >= 0; bit-- {
+			block.Encrypt(stream, reg)
+			in := src[i] >> uint(bit) & 1
+			o := in ^ stream[0]>>7
+			out |= o << uint(bit)
+			c := o
+			if !encrypt {
+				c = in
+			}
+			// Shift the register left by one bit and feed in the ciphertext bit
+			last := len(reg) - 1
+			for j := 0; j < last; j++ {
+				reg[j] = reg[j]<<1 | reg[j+1]>>7
+			}
+			reg[last] = reg[last]<<1 | c
+		}
+		dst[i] = out
+	}
+}
@@ -0,0 +1,163 @@
+package mysql_aes
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// latin1Controls are the bytes Windows-1252 leaves undefined. MySQL's latin1, which is
+// otherwise Windows-1252, maps them to the C1 control characters of the same value.
+var latin1Controls = map[byte]bool{0x81: true, 0x8d: true, 0x8f: true, 0x90: true, 0x9d: true}
+
+// normalizeCharset maps a MySQL character set name or alias to the name used internally
+func normalizeCharset(charset string) (string, error) {
+	switch strings.ToLower(charset) {
+	case "latin1":
+		return "latin1", nil
+	case "cp1252", "windows-1252":
+		return "cp1252", nil
+	case "utf8mb3", "utf8":
+		return "utf8mb3", nil
+	case "utf8mb4":
+		return "utf8mb4", nil
+	case "gbk":
+		return "gbk", nil
+	default:
+		return "", fmt.Errorf("unsupported character set %q", charset)
+	}
+}
+
+// DecodeCharset converts bytes in a MySQL character set (latin1, cp1252, utf8mb3, utf8mb4
+// or gbk) to a UTF-8 string. Byte sequences that are invalid in the character set are errors.
+func DecodeCharset(data []byte, charset string) (string, error) {
+	cs, err := normalizeCharset(charset)
+	if err != nil {
+		return "", err
+	}
+
+	switch cs {
+	case "latin1", "cp1252":
+		var b strings.Builder
+		b.Grow(len(data))
+		for i, c := range data {
+			if latin1Controls[c] {
+				if cs == "cp1252" {
+					return "", fmt.Errorf("byte 0x%02x at offset %d is not defined in %s", c, i, cs)
+				}
+				b.WriteRune(rune(c))
+				continue
+			}
+			b.WriteRune(charmap.Windows1252.DecodeByte(c))
+		}
+		return b.String(), nil
+
+	case "utf8mb3", "utf8mb4":
+		for i := 0; i < len(data); {
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size <= 1 {
+				return "", fmt.Errorf("invalid %s byte sequence at offset %d", cs, i)
+			}
+			if cs == "utf8mb3" && size > 3 {
+				return "", fmt.Errorf("character %U at offset %d is not valid in %s", r, i, cs)
+			}
+			i += size
+		}
+		return string(data), nil
+
+	default: // gbk
+		decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s data: %w", cs, err)
+		}
+		// The decoder substitutes U+FFFD for invalid sequences; GBK itself cannot encode it
+		if strings.ContainsRune(string(decoded), utf8.RuneError) {
+			return "", fmt.Errorf("invalid %s byte sequence", cs)
+		}
+		return string(decoded), nil
+	}
+}
+
+// EncodeCharset converts a UTF-8 string to a MySQL character set, returning an error that
+// names the first character the character set cannot represent
+func EncodeCharset(s string, charset string) ([]byte, error) {
+	cs, err := normalizeCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("input is not valid UTF-8")
+	}
+
+	switch cs {
+	case "latin1", "cp1252":
+		out := make([]byte, 0, len(s))
+		for i, r := range s {
+			if r < 0x100 && latin1Controls[byte(r)] && cs == "latin1" {
+				out = append(out, byte(r))
+				continue
+			}
+			c, ok := charmap.Windows1252.EncodeRune(r)
+			if !ok {
+				return nil, fmt.Errorf("character %q (%U) at offset %d cannot be represented in %s", r, r, i, cs)
+			}
+			out = append(out, c)
+		}
+		return out, nil
+
+	case "utf8mb3":
+		for i, r := range s {
+			if r > 0xffff {
+				return nil, fmt.Errorf("character %q (%U) at offset %d cannot be represented in %s", r, r, i, cs)
+			}
+		}
+		return []byte(s), nil
+
+	case "utf8mb4":
+		return []byte(s), nil
+
+	default: // gbk
+		enc := simplifiedchinese.GBK.NewEncoder()
+		out, err := enc.Bytes([]byte(s))
+		if err == nil {
+			return out, nil
+		}
+		for i, r := range s {
+			if _, err := enc.Bytes([]byte(string(r))); err != nil {
+				return nil, fmt.Errorf("character %q (%U) at offset %d cannot be represented in %s", r, r, i, cs)
+			}
+		}
+		return nil, fmt.Errorf("cannot encode to %s: %w", cs, err)
+	}
+}
+
+// EncryptStringCharset converts plaintext to the given MySQL character set and encrypts it,
+// producing the bytes AES_ENCRYPT sees on a connection using that character set
+func (m *MySQLAES) EncryptStringCharset(plaintext, key, charset string) (string, error) {
+	data, err := EncodeCharset(plaintext, charset)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := m.Encrypt(data, []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(encrypted), nil
+}
+
+// DecryptStringCharset decrypts a hex string and converts the binary AES_DECRYPT result from
+// the given MySQL character set to UTF-8
+func (m *MySQLAES) DecryptStringCharset(ciphertextHex, key, charset string) (string, error) {
+	if _, err := normalizeCharset(charset); err != nil {
+		return "", err
+	}
+	decrypted, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return "", err
+	}
+	return DecodeCharset([]byte(decrypted), charset)
+}
@@ -0,0 +1,115 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCharsetRoundTrip(t *testing.T) {
+	testCases := []struct {
+		charset string
+		text    string
+		encoded []byte
+	}{
+		{"latin1", "café €", []byte{'c', 'a', 'f', 0xe9, ' ', 0x80}},
+		{"latin1", "\u0081", []byte{0x81}},
+		{"cp1252", "naïve “quotes”", []byte{'n', 'a', 0xef, 'v', 'e', ' ', 0x93, 'q', 'u', 'o', 't', 'e', 's', 0x94}},
+		{"utf8mb3", "你好", []byte("你好")},
+		{"utf8", "ü", []byte("ü")},
+		{"utf8mb4", "🌍", []byte("🌍")},
+		{"gbk", "中文", []byte{0xd6, 0xd0, 0xce, 0xc4}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.charset+" "+tc.text, func(t *testing.T) {
+			encoded, err := EncodeCharset(tc.text, tc.charset)
+			if err != nil {
+				t.Fatalf("EncodeCharset failed: %v", err)
+			}
+			if !bytes.Equal(encoded, tc.encoded) {
+				t.Errorf("Expected %x, got %x", tc.encoded, encoded)
+			}
+			decoded, err := DecodeCharset(tc.encoded, tc.charset)
+			if err != nil {
+				t.Fatalf("DecodeCharset failed: %v", err)
+			}
+			if decoded != tc.text {
+				t.Errorf("Expected %q, got %q", tc.text, decoded)
+			}
+		})
+	}
+}
+
+func TestCharsetErrors(t *testing.T) {
+	encodeCases := []struct {
+		charset string
+		text    string
+	}{
+		{"latin1", "中"},
+		{"cp1252", "\u0081"},
+		{"utf8mb3", "🌍"},
+		{"gbk", "🌍"},
+		{"ebcdic", "x"},
+		{"utf8mb4", "\xff"},
+	}
+	for _, tc := range encodeCases {
+		if _, err := EncodeCharset(tc.text, tc.charset); err == nil {
+			t.Errorf("Expected error encoding %q to %s", tc.text, tc.charset)
+		}
+	}
+
+	decodeCases := []struct {
+		charset string
+		data    []byte
+	}{
+		{"cp1252", []byte{0x81}},
+		{"utf8mb4", []byte{0xc3, 0x28}},
+		{"utf8mb3", []byte("🌍")},
+		{"gbk", []byte{0x81, 0x20}},
+	}
+	for _, tc := range decodeCases {
+		if _, err := DecodeCharset(tc.data, tc.charset); err == nil {
+			t.Errorf("Expected error decoding %x from %s", tc.data, tc.charset)
+		}
+	}
+}
+
+func TestMySQLAES_Charset(t *testing.T) {
+	aes := New()
+	key := "legacykey"
+
+	// A row written by a latin1 connection holds latin1 bytes
+	legacy, err := aes.Encrypt([]byte{'M', 0xfc, 'l', 'l', 'e', 'r'}, []byte(key))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	encrypted, err := aes.EncryptStringCharset("Müller", key, "latin1")
+	if err != nil {
+		t.Fatalf("EncryptStringCharset failed: %v", err)
+	}
+	if !bytes.Equal(mustDecodeHex(t, encrypted), legacy) {
+		t.Error("Expected EncryptStringCharset to match latin1 ciphertext")
+	}
+
+	decrypted, err := aes.DecryptStringCharset(encrypted, key, "latin1")
+	if err != nil || decrypted != "Müller" {
+		t.Errorf("Expected %q, got %q, %v", "Müller", decrypted, err)
+	}
+
+	if _, err := aes.DecryptStringCharset(encrypted, key, "utf8mb4"); err == nil {
+		t.Error("Expected error decoding latin1 bytes as utf8mb4")
+	}
+	if _, err := aes.EncryptStringCharset("中", key, "latin1"); err == nil {
+		t.Error("Expected error for unrepresentable character")
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Invalid hex %q: %v", s, err)
+	}
+	return data
+}
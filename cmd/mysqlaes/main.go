@@ -0,0 +1,259 @@
+// Command mysqlaes transforms database exports with MySQL-compatible AES encryption.
+//
+// Usage:
+//
+//	mysqlaes dump -mode encrypt -key secret -columns users.email,users.phone < dump.sql > encrypted.sql
+//	mysqlaes outfile -mode decrypt -key secret -format csv -columns email < export.csv > plain.csv
+//	mysqlaes openssl -key secret -block-mode aes-256-cbc -iv 000102030405060708090a0b0c0d0e0f
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ace3/mysql-aes"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = runDump(os.Args[2:], os.Stdin, os.Stdout)
+	case "outfile":
+		err = runOutfile(os.Args[2:], os.Stdin, os.Stdout)
+	case "openssl":
+		err = runOpenSSL(os.Args[2:], os.Stdout)
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mysqlaes: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: mysqlaes <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  dump    encrypt or decrypt columns of mysqldump output")
+	fmt.Fprintln(os.Stderr, "  outfile encrypt or decrypt columns of CSV and INTO OUTFILE files")
+	fmt.Fprintln(os.Stderr, "  openssl print the openssl enc command that reproduces an encryption")
+}
+
+// runDump implements the dump command, reading mysqldump SQL from in and writing to out
+func runDump(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	mode := fs.String("mode", "encrypt", "encrypt or decrypt")
+	key := fs.String("key", os.Getenv("MYSQL_AES_KEY"), "encryption key (defaults to $MYSQL_AES_KEY)")
+	columns := fs.String("columns", "", "comma separated table.column list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	m, err := mysql_aes.ParseTransformMode(*mode)
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		return fmt.Errorf("a key is required")
+	}
+	cols, err := parseColumns(*columns)
+	if err != nil {
+		return err
+	}
+
+	t := mysql_aes.NewDumpTransformer(mysql_aes.New(), m, *key, cols)
+	return t.Transform(in, out)
+}
+
+// runOutfile implements the outfile command for CSV and SELECT ... INTO OUTFILE files
+func runOutfile(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("outfile", flag.ContinueOnError)
+	mode := fs.String("mode", "encrypt", "encrypt or decrypt")
+	key := fs.String("key", os.Getenv("MYSQL_AES_KEY"), "encryption key (defaults to $MYSQL_AES_KEY)")
+	preset := fs.String("format", "tsv", "base format: tsv (INTO OUTFILE defaults) or csv")
+	fieldsTerminated := fs.String("fields-terminated-by", "", "FIELDS TERMINATED BY string")
+	enclosed := fs.String("enclosed-by", "", "FIELDS ENCLOSED BY character")
+	optionally := fs.Bool("optionally-enclosed", false, "use OPTIONALLY ENCLOSED BY")
+	escaped := fs.String("escaped-by", "", "FIELDS ESCAPED BY character")
+	linesStarting := fs.String("lines-starting-by", "", "LINES STARTING BY string")
+	linesTerminated := fs.String("lines-terminated-by", "", "LINES TERMINATED BY string")
+	header := fs.Bool("header", false, "first line holds column names")
+	fields := fs.String("fields", "", "comma separated field names when there is no header")
+	columns := fs.String("columns", "", "comma separated columns to transform")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	m, err := mysql_aes.ParseTransformMode(*mode)
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		return fmt.Errorf("a key is required")
+	}
+
+	var format mysql_aes.OutfileFormat
+	switch *preset {
+	case "tsv":
+		format = mysql_aes.DefaultOutfileFormat()
+	case "csv":
+		format = mysql_aes.CSVOutfileFormat()
+	default:
+		return fmt.Errorf("unknown format %q", *preset)
+	}
+
+	// Explicit flags override the preset, including setting an option to empty
+	var ferr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "fields-terminated-by":
+			format.FieldsTerminatedBy = unescapeFlag(*fieldsTerminated)
+		case "enclosed-by":
+			format.FieldsEnclosedBy, ferr = singleByte(f.Name, unescapeFlag(*enclosed))
+		case "optionally-enclosed":
+			format.OptionallyEnclosed = *optionally
+		case "escaped-by":
+			format.FieldsEscapedBy, ferr = singleByte(f.Name, unescapeFlag(*escaped))
+		case "lines-starting-by":
+			format.LinesStartingBy = unescapeFlag(*linesStarting)
+		case "lines-terminated-by":
+			format.LinesTerminatedBy = unescapeFlag(*linesTerminated)
+		case "header":
+			format.Header = *header
+		}
+	})
+	if ferr != nil {
+		return ferr
+	}
+
+	targets := splitList(*columns)
+	if len(targets) == 0 {
+		return fmt.Errorf("at least one column is required")
+	}
+
+	t := mysql_aes.NewOutfileTransformer(mysql_aes.New(), m, *key, format, splitList(*fields), targets)
+	return t.Transform(in, out)
+}
+
+// runOpenSSL implements the openssl command, printing the `openssl enc` command line that
+// reproduces AES_ENCRYPT (or AES_DECRYPT with -mode decrypt) for the key and block mode
+func runOpenSSL(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("openssl", flag.ContinueOnError)
+	mode := fs.String("mode", "encrypt", "encrypt or decrypt")
+	key := fs.String("key", os.Getenv("MYSQL_AES_KEY"), "encryption key (defaults to $MYSQL_AES_KEY)")
+	blockMode := fs.String("block-mode", mysql_aes.DefaultBlockEncryptionMode, "block_encryption_mode, e.g. aes-256-cbc")
+	ivHex := fs.String("iv", "", "hex encoded initialization vector for modes other than ECB")
+	profile := fs.String("profile", "mysql", "server profile: mysql, mariadb or mariadb-legacy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	m, err := mysql_aes.ParseTransformMode(*mode)
+	if err != nil {
+		return err
+	}
+	if *key == "" {
+		return fmt.Errorf("a key is required")
+	}
+	p, err := mysql_aes.ParseProfile(*profile)
+	if err != nil {
+		return err
+	}
+	var iv []byte
+	if *ivHex != "" {
+		if iv, err = hex.DecodeString(*ivHex); err != nil {
+			return fmt.Errorf("invalid -iv: %w", err)
+		}
+	}
+
+	cmd, err := mysql_aes.NewWithProfile(p).OpenSSLCommand([]byte(*key), iv, *blockMode, m == mysql_aes.TransformDecrypt)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, cmd)
+	return err
+}
+
+// unescapeFlag expands the backslash sequences accepted in SQL string literals
+func unescapeFlag(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// singleByte converts an option that MySQL limits to a single character
+func singleByte(name, value string) (byte, error) {
+	switch len(value) {
+	case 0:
+		return 0, nil
+	case 1:
+		return value[0], nil
+	default:
+		return 0, fmt.Errorf("-%s must be a single character", name)
+	}
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseColumns parses a "table.column,table.column" list into a table to columns mapping
+func parseColumns(list string) (map[string][]string, error) {
+	cols := make(map[string][]string)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		table, column, ok := strings.Cut(item, ".")
+		if !ok || table == "" || column == "" {
+			return nil, fmt.Errorf("invalid column %q, expected table.column", item)
+		}
+		cols[table] = append(cols[table], column)
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("at least one table.column is required")
+	}
+	return cols, nil
+}
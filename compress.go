@@ -0,0 +1,139 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// compressLengthMask keeps the bits of the COMPRESS length header that UNCOMPRESS reads
+const compressLengthMask = 0x3FFFFFFF
+
+// MaxUncompressedLength is the default max_allowed_packet (64 MiB). UNCOMPRESS returns NULL
+// for values whose declared length is larger, and Uncompress rejects them with ErrNullResult.
+const MaxUncompressedLength = 64 << 20
+
+// Compress implements MySQL's COMPRESS(): a 4-byte little-endian uncompressed length
+// followed by a zlib stream, with a '.' appended when the result would end in a space.
+// The empty string compresses to the empty string.
+//
+// UNCOMPRESS reads the output, but Go's deflate encoder does not produce the exact bytes of
+// the zlib library linked into MySQL, so compare decompressed values rather than COMPRESS results.
+func Compress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	if len(data) > compressLengthMask {
+		return nil, fmt.Errorf("data too long to compress")
+	}
+
+	var buf bytes.Buffer
+	var header [4]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(data)))
+	buf.Write(header[:])
+
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+
+	// MySQL protects the value against CHAR end-space trimming
+	if buf.Bytes()[buf.Len()-1] == ' ' {
+		buf.WriteByte('.')
+	}
+	return buf.Bytes(), nil
+}
+
+// Uncompress implements MySQL's UNCOMPRESS(), the inverse of Compress and COMPRESS()
+func Uncompress(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return []byte{}, nil
+	}
+	length, err := UncompressedLength(data)
+	if err != nil {
+		return nil, err
+	}
+	if length > MaxUncompressedLength {
+		return nil, fmt.Errorf("%w: uncompressed length %d exceeds max_allowed_packet", ErrNullResult, length)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(data[4:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %w", err)
+	}
+	defer r.Close()
+
+	// The declared length is not trusted for the allocation; read one byte past it so a
+	// longer stream is detected
+	out, err := io.ReadAll(io.LimitReader(r, int64(length)+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %w", err)
+	}
+	if len(out) < length {
+		return nil, fmt.Errorf("invalid compressed data: %w", io.ErrUnexpectedEOF)
+	}
+	// The stream must end exactly at the declared length
+	if len(out) > length {
+		return nil, fmt.Errorf("compressed data is longer than its declared length %d", length)
+	}
+	return out, nil
+}
+
+// UncompressedLength implements MySQL's UNCOMPRESSED_LENGTH()
+func UncompressedLength(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if len(data) <= 4 {
+		return 0, fmt.Errorf("compressed data too short")
+	}
+	return int(binary.LittleEndian.Uint32(data) & compressLengthMask), nil
+}
+
+// EncryptCompressed compresses and encrypts plaintext, matching AES_ENCRYPT(COMPRESS(str), key).
+// An empty plaintext is allowed and encrypts the empty string, as COMPRESS of an empty
+// string is empty in MySQL.
+func (m *MySQLAES) EncryptCompressed(plaintext, key []byte) ([]byte, error) {
+	compressed, err := Compress(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return m.encrypt(compressed, key)
+}
+
+// DecryptCompressed decrypts and decompresses ciphertext, matching UNCOMPRESS(AES_DECRYPT(crypt_str, key))
+func (m *MySQLAES) DecryptCompressed(ciphertext, key []byte) ([]byte, error) {
+	compressed, err := m.Decrypt(ciphertext, key)
+	if err != nil {
+		return nil, err
+	}
+	return Uncompress(compressed)
+}
+
+// EncryptCompressedString compresses and encrypts a string and returns the result as a hex string
+func (m *MySQLAES) EncryptCompressedString(plaintext, key string) (string, error) {
+	encrypted, err := m.EncryptCompressed([]byte(plaintext), []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(encrypted), nil
+}
+
+// DecryptCompressedString decrypts and decompresses a hex string
+func (m *MySQLAES) DecryptCompressedString(ciphertextHex, key string) (string, error) {
+	ciphertext, err := hex.DecodeString(ciphertextHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex string: %w", err)
+	}
+	decrypted, err := m.DecryptCompressed(ciphertext, []byte(key))
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
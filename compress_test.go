@@ -0,0 +1,149 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUncompress_MySQLVector(t *testing.T) {
+	// SELECT HEX(COMPRESS('a'))
+	data, _ := hex.DecodeString("01000000789C4B040000620062")
+	out, err := Uncompress(data)
+	if err != nil {
+		t.Fatalf("Uncompress failed: %v", err)
+	}
+	if string(out) != "a" {
+		t.Errorf("Expected %q, got %q", "a", out)
+	}
+
+	n, err := UncompressedLength(data)
+	if err != nil || n != 1 {
+		t.Errorf("Expected length 1, got %d, %v", n, err)
+	}
+}
+
+func TestCompress_RoundTrip(t *testing.T) {
+	testCases := []string{
+		"",
+		"a",
+		strings.Repeat("compressible text ", 500),
+		"Unicode: 你好世界 🌍",
+	}
+
+	for _, tc := range testCases {
+		compressed, err := Compress([]byte(tc))
+		if err != nil {
+			t.Fatalf("Compress failed: %v", err)
+		}
+		if tc == "" && len(compressed) != 0 {
+			t.Errorf("Expected COMPRESS('') to be empty, got %x", compressed)
+		}
+		out, err := Uncompress(compressed)
+		if err != nil {
+			t.Fatalf("Uncompress failed: %v", err)
+		}
+		if string(out) != tc {
+			t.Errorf("Expected %q, got %q", tc, out)
+		}
+	}
+}
+
+func TestCompress_TrailingSpace(t *testing.T) {
+	// Find an input whose zlib stream ends in a space to exercise MySQL's '.' suffix
+	for i := 0; i < 100000; i++ {
+		input := []byte(fmt.Sprintf("value-%d", i))
+		compressed, err := Compress(input)
+		if err != nil {
+			t.Fatalf("Compress failed: %v", err)
+		}
+		if compressed[len(compressed)-2] != ' ' {
+			continue
+		}
+		if compressed[len(compressed)-1] != '.' {
+			t.Fatalf("Expected '.' after trailing space, got %x", compressed)
+		}
+		out, err := Uncompress(compressed)
+		if err != nil || !bytes.Equal(out, input) {
+			t.Fatalf("Expected %q, got %q, %v", input, out, err)
+		}
+		return
+	}
+	t.Fatal("No input with a trailing space found")
+}
+
+func TestUncompress_Errors(t *testing.T) {
+	valid, _ := Compress([]byte("hello world"))
+	wrongLength := append([]byte{}, valid...)
+	wrongLength[0] = 5
+	// Declares 48 MiB, within max_allowed_packet, for an 11-byte stream
+	overstated := append([]byte{0, 0, 0, 3}, valid[4:]...)
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"too short", []byte{1, 0, 0, 0}},
+		{"not zlib", []byte{1, 0, 0, 0, 'x', 'y', 'z'}},
+		{"length mismatch", wrongLength},
+		{"truncated", valid[:len(valid)-4]},
+		{"overstated length", overstated},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Uncompress(tc.data); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	// A 5-byte value can declare up to 1 GiB; MySQL returns NULL above max_allowed_packet
+	if _, err := Uncompress([]byte{0xff, 0xff, 0xff, 0x3f, 'x'}); !errors.Is(err, ErrNullResult) {
+		t.Errorf("Expected ErrNullResult, got %v", err)
+	}
+}
+
+func TestMySQLAES_Compressed(t *testing.T) {
+	aes := New()
+	key := "docs-key"
+	doc := strings.Repeat("<p>Large document body</p>", 200)
+
+	encrypted, err := aes.EncryptCompressedString(doc, key)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	plain, _ := aes.EncryptString(doc, key)
+	if len(encrypted) >= len(plain) {
+		t.Error("Expected compressed ciphertext to be smaller")
+	}
+
+	decrypted, err := aes.DecryptCompressedString(encrypted, key)
+	if err != nil || decrypted != doc {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+
+	// AES_ENCRYPT(COMPRESS(''), k) is a single padding block
+	empty, err := aes.EncryptCompressedString("", key)
+	if err != nil {
+		t.Fatalf("Encryption of empty string failed: %v", err)
+	}
+	if len(empty) != 2*BlockSize {
+		t.Errorf("Expected one block, got %q", empty)
+	}
+	decrypted, err = aes.DecryptCompressedString(empty, key)
+	if err != nil || decrypted != "" {
+		t.Errorf("Expected empty string, got %q, %v", decrypted, err)
+	}
+
+	// Data compressed by MySQL decrypts too
+	mysqlCompressed, _ := hex.DecodeString("01000000789C4B040000620062")
+	ciphertext, _ := aes.Encrypt(mysqlCompressed, []byte(key))
+	out, err := aes.DecryptCompressed(ciphertext, []byte(key))
+	if err != nil || string(out) != "a" {
+		t.Errorf("Expected %q, got %q, %v", "a", out, err)
+	}
+}
@@ -0,0 +1,110 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestContextBinder_ECB(t *testing.T) {
+	binder := NewContextBinder("S4ty7H3mhy9sdaP54TRVne6ABDSafKqZ")
+	alice := Binding{Table: "users", Column: "ssn", RowID: 1}
+
+	encrypted, err := binder.Encrypt("123-45-6789", alice)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	decrypted, err := binder.Decrypt(encrypted, alice)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if decrypted != "123-45-6789" {
+		t.Errorf("Expected %q, got %q", "123-45-6789", decrypted)
+	}
+
+	// The value is plain MySQL-compatible ciphertext under the derived key
+	direct, err := New().DecryptString(encrypted, string(binder.DeriveKey(alice)))
+	if err != nil || direct != decrypted {
+		t.Errorf("Expected derived key to decrypt the value, got %q, %v", direct, err)
+	}
+
+	others := []Binding{
+		{Table: "users", Column: "ssn", RowID: 2},
+		{Table: "users", Column: "phone", RowID: 1},
+		{Table: "admins", Column: "ssn", RowID: 1},
+	}
+	mismatches := 0
+	for _, other := range others {
+		result, err := binder.Decrypt(encrypted, other)
+		if err == nil && result == decrypted {
+			t.Errorf("Expected %+v not to decrypt the value", other)
+		}
+		if errors.Is(err, ErrContextMismatch) {
+			mismatches++
+		}
+	}
+	if mismatches == 0 {
+		t.Error("Expected ErrContextMismatch for swapped values")
+	}
+}
+
+func TestContextBinder_GCM(t *testing.T) {
+	binder := NewContextBinder("base-key")
+	alice := Binding{Table: "users", Column: "ssn", RowID: uint64(1)}
+
+	encrypted, err := binder.EncryptGCM("123-45-6789", alice)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	decrypted, err := binder.DecryptGCM(encrypted, alice)
+	if err != nil || decrypted != "123-45-6789" {
+		t.Fatalf("Decryption failed: %q, %v", decrypted, err)
+	}
+
+	// Equivalent row ID types bind the same way
+	if _, err := binder.DecryptGCM(encrypted, Binding{Table: "users", Column: "ssn", RowID: "1"}); err != nil {
+		t.Errorf("Expected string row ID to match: %v", err)
+	}
+
+	_, err = binder.DecryptGCM(encrypted, Binding{Table: "users", Column: "ssn", RowID: 2})
+	if !errors.Is(err, ErrContextMismatch) {
+		t.Errorf("Expected ErrContextMismatch, got %v", err)
+	}
+}
+
+func TestBinding_AssociatedData(t *testing.T) {
+	a := Binding{Table: "a:b", Column: "c", RowID: 1}.AssociatedData()
+	b := Binding{Table: "a", Column: "b:c", RowID: 1}.AssociatedData()
+	if bytes.Equal(a, b) {
+		t.Error("Expected associated data to be unambiguous")
+	}
+}
+
+func TestContextBinder_DeriveKey(t *testing.T) {
+	binder := NewContextBinder("base")
+
+	// SHA-256("base5:users3:ssn:1"), as SHA2(CONCAT(...), 256) computes it in MySQL
+	expected := "4b176a3162ed9af02b5f1e3f75ccd7abb15921654f2cf64c7005c946d00d98b9"
+	if got := hex.EncodeToString(binder.DeriveKey(Binding{Table: "users", Column: "ssn", RowID: 1})); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	a := binder.DeriveKey(Binding{Table: "a:b", Column: "c", RowID: 1})
+	b := binder.DeriveKey(Binding{Table: "a", Column: "b:c", RowID: 1})
+	if bytes.Equal(a, b) {
+		t.Error("Expected distinct keys for table \"a:b\" column \"c\" and table \"a\" column \"b:c\"")
+	}
+}
+
+func TestContextBinder_KeySQL(t *testing.T) {
+	binder := NewContextBinder("it's-a-key")
+	expected := "UNHEX(SHA2(CONCAT('it\\'s-a-key', LENGTH('users'), ':', 'users', LENGTH('ssn'), ':', 'ssn', ':', `id`), 256))"
+	if got := binder.KeySQL("users", "ssn", "`id`"); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	if _, err := NewContextBinder("").Encrypt("x", Binding{}); err == nil {
+		t.Error("Expected error for empty base key")
+	}
+}
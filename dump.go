@@ -0,0 +1,672 @@
+package mysql_aes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TransformMode selects whether a transformer encrypts or decrypts the configured columns
+type TransformMode int
+
+const (
+	// TransformEncrypt encrypts plaintext column values
+	TransformEncrypt TransformMode = iota
+	// TransformDecrypt decrypts ciphertext column values
+	TransformDecrypt
+)
+
+// String returns the name of the mode
+func (m TransformMode) String() string {
+	switch m {
+	case TransformEncrypt:
+		return "encrypt"
+	case TransformDecrypt:
+		return "decrypt"
+	default:
+		return fmt.Sprintf("TransformMode(%d)", int(m))
+	}
+}
+
+// ParseTransformMode parses "encrypt" or "decrypt" into a TransformMode
+func ParseTransformMode(s string) (TransformMode, error) {
+	switch strings.ToLower(s) {
+	case "encrypt":
+		return TransformEncrypt, nil
+	case "decrypt":
+		return TransformDecrypt, nil
+	default:
+		return 0, fmt.Errorf("unknown transform mode %q", s)
+	}
+}
+
+// DumpTransformer rewrites mysqldump output, encrypting or decrypting selected columns
+// of the INSERT statements while copying everything else through unchanged.
+//
+// Column positions are taken from the column list of the INSERT statement when present
+// (mysqldump --complete-insert) and otherwise from the preceding CREATE TABLE statement.
+// Quoted string values are treated like EncryptString/DecryptString values (hex ciphertext),
+// while _binary '...' and 0x... literals are treated as raw binary ciphertext and keep their
+// literal form. NULL and empty strings are copied unchanged.
+type DumpTransformer struct {
+	aes     *MySQLAES
+	mode    TransformMode
+	key     string
+	columns map[string]map[string]bool
+	tables  map[string][]string
+}
+
+// NewDumpTransformer creates a DumpTransformer for the given table to column names mapping
+func NewDumpTransformer(m *MySQLAES, mode TransformMode, key string, columns map[string][]string) *DumpTransformer {
+	cols := make(map[string]map[string]bool, len(columns))
+	for table, names := range columns {
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			set[strings.ToLower(name)] = true
+		}
+		cols[strings.ToLower(table)] = set
+	}
+	return &DumpTransformer{
+		aes:     m,
+		mode:    mode,
+		key:     key,
+		columns: cols,
+		tables:  make(map[string][]string),
+	}
+}
+
+// Transform reads mysqldump SQL from r and writes the transformed SQL to w.
+// Statements are processed one at a time, so the whole dump is never held in memory.
+func (t *DumpTransformer) Transform(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	bw := bufio.NewWriterSize(w, 64*1024)
+
+	var createTable string
+	lineNo := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		lineNo++
+
+		switch {
+		case createTable != "":
+			if strings.HasPrefix(line, ")") {
+				createTable = ""
+			} else if name, ok := columnDefinitionName(line); ok {
+				t.tables[createTable] = append(t.tables[createTable], name)
+			}
+		case hasPrefixFold(line, "CREATE TABLE"):
+			if name, ok := createTableName(line); ok {
+				createTable = strings.ToLower(name)
+				t.tables[createTable] = nil
+			}
+		case hasPrefixFold(line, "INSERT") || hasPrefixFold(line, "REPLACE"):
+			startLine := lineNo
+			// A statement only ends on a line whose end is outside any quoted literal
+			for err == nil && !statementComplete(line) {
+				var more string
+				more, err = br.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return err
+				}
+				line += more
+				lineNo++
+			}
+			out, terr := t.transformInsert(line)
+			if terr != nil {
+				return fmt.Errorf("line %d: %w", startLine, terr)
+			}
+			line = out
+		}
+
+		if _, werr := bw.WriteString(line); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return bw.Flush()
+}
+
+// transformInsert rewrites a single INSERT or REPLACE statement
+func (t *DumpTransformer) transformInsert(stmt string) (string, error) {
+	p := &sqlScanner{s: stmt}
+
+	// INSERT [LOW_PRIORITY|DELAYED|HIGH_PRIORITY] [IGNORE] [INTO] tbl | REPLACE ...
+	for {
+		p.skipSpace()
+		word := p.peekWord()
+		if word == "" {
+			break
+		}
+		p.pos += len(word)
+	}
+	p.skipSpace()
+	table, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	// Allow db.table, keeping only the table part
+	if p.peek() == '.' {
+		p.pos++
+		if table, err = p.identifier(); err != nil {
+			return "", err
+		}
+	}
+
+	targets := t.columns[strings.ToLower(table)]
+	if len(targets) == 0 {
+		return stmt, nil
+	}
+
+	columns := t.tables[strings.ToLower(table)]
+	p.skipSpace()
+	if p.peek() == '(' {
+		p.pos++
+		columns = nil
+		for {
+			p.skipSpace()
+			name, err := p.identifier()
+			if err != nil {
+				return "", err
+			}
+			columns = append(columns, name)
+			p.skipSpace()
+			if p.peek() == ',' {
+				p.pos++
+				continue
+			}
+			if err := p.expect(')'); err != nil {
+				return "", err
+			}
+			break
+		}
+	}
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no column list or CREATE TABLE found for table %s", table)
+	}
+
+	transform := make([]bool, len(columns))
+	for i, name := range columns {
+		transform[i] = targets[strings.ToLower(name)]
+	}
+
+	p.skipSpace()
+	if word := p.peekWord(); !strings.EqualFold(word, "VALUES") && !strings.EqualFold(word, "VALUE") {
+		return "", fmt.Errorf("expected VALUES for table %s", table)
+	}
+	p.pos += len(p.peekWord())
+
+	var out strings.Builder
+	out.Grow(len(stmt) * 2)
+	out.WriteString(stmt[:p.pos])
+
+	for row := 1; ; row++ {
+		start := p.pos
+		p.skipSpace()
+		if err := p.expect('('); err != nil {
+			return "", err
+		}
+		out.WriteString(stmt[start:p.pos])
+
+		for col := 0; ; col++ {
+			start = p.pos
+			p.skipSpace()
+			out.WriteString(stmt[start:p.pos])
+
+			lit, err := p.literal()
+			if err != nil {
+				return "", fmt.Errorf("row %d: %w", row, err)
+			}
+			if col >= len(columns) {
+				return "", fmt.Errorf("row %d has more values than table %s has columns", row, table)
+			}
+			if transform[col] {
+				text, err := t.transformLiteral(lit)
+				if err != nil {
+					return "", fmt.Errorf("table %s row %d column %s: %w", table, row, columns[col], err)
+				}
+				out.WriteString(text)
+			} else {
+				out.WriteString(lit.raw)
+			}
+
+			start = p.pos
+			p.skipSpace()
+			c := p.next()
+			out.WriteString(stmt[start:p.pos])
+			if c == ',' {
+				continue
+			}
+			if c != ')' {
+				return "", fmt.Errorf("row %d: unexpected %q in value list", row, c)
+			}
+			break
+		}
+
+		start = p.pos
+		p.skipSpace()
+		c := p.peek()
+		if c == ',' {
+			p.pos++
+			out.WriteString(stmt[start:p.pos])
+			continue
+		}
+		// Trailing clauses such as ON DUPLICATE KEY UPDATE and the terminator are copied as is
+		out.WriteString(stmt[start:])
+		return out.String(), nil
+	}
+}
+
+// transformLiteral encrypts or decrypts a single value literal
+func (t *DumpTransformer) transformLiteral(lit sqlLiteral) (string, error) {
+	switch lit.kind {
+	case literalString:
+		if len(lit.value) == 0 {
+			return lit.raw, nil
+		}
+		var result string
+		var err error
+		if t.mode == TransformEncrypt {
+			result, err = t.aes.EncryptString(string(lit.value), t.key)
+		} else {
+			result, err = t.aes.DecryptString(string(lit.value), t.key)
+		}
+		if err != nil {
+			return "", err
+		}
+		return lit.prefix + "'" + escapeSQLString([]byte(result)) + "'", nil
+	case literalBinaryString, literalHex:
+		if len(lit.value) == 0 {
+			return lit.raw, nil
+		}
+		var result []byte
+		var err error
+		if t.mode == TransformEncrypt {
+			result, err = t.aes.Encrypt(lit.value, []byte(t.key))
+		} else {
+			result, err = t.aes.Decrypt(lit.value, []byte(t.key))
+		}
+		if err != nil {
+			return "", err
+		}
+		if lit.kind == literalHex {
+			return lit.prefix + "0x" + strings.ToUpper(hex.EncodeToString(result)), nil
+		}
+		return lit.prefix + "'" + escapeSQLString(result) + "'", nil
+	default:
+		// NULL, numbers and expressions are left untouched
+		return lit.raw, nil
+	}
+}
+
+// literalKind classifies value literals found in INSERT statements
+type literalKind int
+
+const (
+	literalOther literalKind = iota
+	literalNull
+	literalString
+	literalBinaryString
+	literalHex
+)
+
+// sqlLiteral is a parsed value literal together with its original text
+type sqlLiteral struct {
+	kind   literalKind
+	raw    string
+	prefix string // charset introducer such as "_binary " or "_utf8mb4"
+	value  []byte
+}
+
+// sqlScanner is a minimal cursor over a single SQL statement
+type sqlScanner struct {
+	s   string
+	pos int
+}
+
+func (p *sqlScanner) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *sqlScanner) next() byte {
+	c := p.peek()
+	if p.pos < len(p.s) {
+		p.pos++
+	}
+	return c
+}
+
+func (p *sqlScanner) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *sqlScanner) skipSpace() {
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peekWord returns the bare keyword at the cursor without consuming it
+func (p *sqlScanner) peekWord() string {
+	end := p.pos
+	for end < len(p.s) && isWordChar(p.s[end]) {
+		end++
+	}
+	word := p.s[p.pos:end]
+	switch strings.ToUpper(word) {
+	case "INSERT", "REPLACE", "IGNORE", "INTO", "LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "VALUES", "VALUE":
+		return word
+	}
+	return ""
+}
+
+// identifier reads a backquoted or bare identifier
+func (p *sqlScanner) identifier() (string, error) {
+	if p.peek() != '`' {
+		start := p.pos
+		for p.pos < len(p.s) && isWordChar(p.s[p.pos]) {
+			p.pos++
+		}
+		if start == p.pos {
+			return "", fmt.Errorf("expected identifier at offset %d", start)
+		}
+		return p.s[start:p.pos], nil
+	}
+	p.pos++
+	var name strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		if c == '`' {
+			if p.peek() == '`' {
+				p.pos++
+				name.WriteByte('`')
+				continue
+			}
+			return name.String(), nil
+		}
+		name.WriteByte(c)
+	}
+	return "", fmt.Errorf("unterminated identifier")
+}
+
+// literal reads one value from a VALUES tuple
+func (p *sqlScanner) literal() (sqlLiteral, error) {
+	start := p.pos
+	c := p.peek()
+
+	switch {
+	case c == '\'' || c == '"':
+		value, err := p.quoted()
+		if err != nil {
+			return sqlLiteral{}, err
+		}
+		return sqlLiteral{kind: literalString, raw: p.s[start:p.pos], value: value}, nil
+
+	case c == '_':
+		// Charset introducer: _binary '...', _utf8mb4'...', _binary 0x...
+		end := p.pos + 1
+		for end < len(p.s) && isWordChar(p.s[end]) {
+			end++
+		}
+		charset := p.s[p.pos:end]
+		p.pos = end
+		p.skipSpace()
+		prefix := p.s[start:p.pos]
+		if q := p.peek(); q == '\'' || q == '"' {
+			value, err := p.quoted()
+			if err != nil {
+				return sqlLiteral{}, err
+			}
+			kind := literalString
+			if strings.EqualFold(charset, "_binary") {
+				kind = literalBinaryString
+			}
+			return sqlLiteral{kind: kind, raw: p.s[start:p.pos], prefix: prefix, value: value}, nil
+		}
+		if p.peek() == '0' || p.peek() == 'x' || p.peek() == 'X' {
+			lit, err := p.literal()
+			if err == nil && lit.kind == literalHex {
+				lit.raw = p.s[start:p.pos]
+				lit.prefix = prefix
+				return lit, nil
+			}
+		}
+		p.pos = start
+
+	case (c == '0' && p.pos+1 < len(p.s) && (p.s[p.pos+1] == 'x' || p.s[p.pos+1] == 'X')):
+		end := p.pos + 2
+		for end < len(p.s) && isHexChar(p.s[end]) {
+			end++
+		}
+		digits := p.s[p.pos+2 : end]
+		if len(digits)%2 == 1 {
+			digits = "0" + digits
+		}
+		value, err := hex.DecodeString(digits)
+		if err != nil {
+			return sqlLiteral{}, fmt.Errorf("invalid hex literal: %w", err)
+		}
+		p.pos = end
+		return sqlLiteral{kind: literalHex, raw: p.s[start:p.pos], value: value}, nil
+
+	case (c == 'x' || c == 'X') && p.pos+1 < len(p.s) && p.s[p.pos+1] == '\'':
+		end := strings.IndexByte(p.s[p.pos+2:], '\'')
+		if end < 0 {
+			return sqlLiteral{}, fmt.Errorf("unterminated hex literal")
+		}
+		value, err := hex.DecodeString(p.s[p.pos+2 : p.pos+2+end])
+		if err != nil {
+			return sqlLiteral{}, fmt.Errorf("invalid hex literal: %w", err)
+		}
+		p.pos += end + 3
+		return sqlLiteral{kind: literalHex, raw: p.s[start:p.pos], value: value}, nil
+	}
+
+	// Bare token: NULL, numbers, or a simple expression; ends at a top-level ',' or ')'
+	depth := 0
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '\'' || c == '"' {
+			if _, err := p.quoted(); err != nil {
+				return sqlLiteral{}, err
+			}
+			continue
+		}
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if c == ',' && depth == 0 {
+			break
+		}
+		p.pos++
+	}
+	raw := strings.TrimRight(p.s[start:p.pos], " \t\r\n")
+	p.pos = start + len(raw)
+	if raw == "" {
+		return sqlLiteral{}, fmt.Errorf("expected value at offset %d", start)
+	}
+	if strings.EqualFold(raw, "NULL") {
+		return sqlLiteral{kind: literalNull, raw: raw}, nil
+	}
+	return sqlLiteral{kind: literalOther, raw: raw}, nil
+}
+
+// quoted reads a single or double quoted string literal and unescapes it
+func (p *sqlScanner) quoted() ([]byte, error) {
+	quote := p.next()
+	var buf bytes.Buffer
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.s):
+			e := p.s[p.pos]
+			p.pos++
+			buf.Write(unescapeSQLChar(e))
+		case c == quote:
+			if p.peek() == quote {
+				p.pos++
+				buf.WriteByte(quote)
+				continue
+			}
+			return buf.Bytes(), nil
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return nil, fmt.Errorf("unterminated string literal")
+}
+
+// unescapeSQLChar maps the character after a backslash to its value, following MySQL's rules
+func unescapeSQLChar(c byte) []byte {
+	switch c {
+	case '0':
+		return []byte{0}
+	case 'b':
+		return []byte{'\b'}
+	case 'n':
+		return []byte{'\n'}
+	case 'r':
+		return []byte{'\r'}
+	case 't':
+		return []byte{'\t'}
+	case 'Z':
+		return []byte{0x1a}
+	case '%', '_':
+		// MySQL keeps the backslash for the LIKE wildcards
+		return []byte{'\\', c}
+	default:
+		return []byte{c}
+	}
+}
+
+// escapeSQLString escapes data the same way mysql_real_escape_string (and thus mysqldump) does
+func escapeSQLString(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data) + len(data)/8)
+	for _, c := range data {
+		switch c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// statementComplete reports whether s ends with a ';' that is outside of any quoted literal.
+// Backslash escapes apply to string literals only; in backquoted identifiers a backslash is
+// literal and a doubled backquote closes and reopens the quote.
+func statementComplete(s string) bool {
+	var quote byte
+	last := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' && quote != '`' {
+				i++
+				continue
+			}
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		last = c
+	}
+	return quote == 0 && last == ';'
+}
+
+// createTableName extracts the table name from a "CREATE TABLE `name` (" line
+func createTableName(line string) (string, bool) {
+	p := &sqlScanner{s: line, pos: len("CREATE TABLE")}
+	p.skipSpace()
+	if rest := p.s[p.pos:]; hasPrefixFold(rest, "IF NOT EXISTS") {
+		p.pos += len("IF NOT EXISTS")
+		p.skipSpace()
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return "", false
+	}
+	if p.peek() == '.' {
+		p.pos++
+		if name, err = p.identifier(); err != nil {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// columnDefinitionName returns the column name of a CREATE TABLE column definition line.
+// Index and constraint lines do not start with a backquoted name and are ignored.
+func columnDefinitionName(line string) (string, bool) {
+	p := &sqlScanner{s: line}
+	p.skipSpace()
+	if p.peek() != '`' {
+		return "", false
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isHexChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
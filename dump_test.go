@@ -0,0 +1,152 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const sampleDump = "-- MySQL dump 10.13  Distrib 8.0.36, for Linux (x86_64)\n" +
+	"DROP TABLE IF EXISTS `users`;\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+	"  `email` varchar(255) DEFAULT NULL,\n" +
+	"  `avatar` varbinary(255) DEFAULT NULL,\n" +
+	"  `note` text,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  KEY `idx_email` (`email`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n" +
+	"LOCK TABLES `users` WRITE;\n" +
+	"INSERT INTO `users` VALUES (1,'alice@example.com',_binary 'a\\0b\\'c','it\\'s, (fine)'),(2,NULL,0x0102FF,'line\\nbreak'),(3,'O\\'Brien \\\\ co',NULL,'');\n" +
+	"INSERT INTO `other` VALUES (1,'untouched');\n" +
+	"UNLOCK TABLES;\n"
+
+func TestDumpTransformer_RoundTrip(t *testing.T) {
+	aes := New()
+	key := "dumpkey"
+	columns := map[string][]string{"users": {"email", "avatar"}}
+
+	var encrypted bytes.Buffer
+	enc := NewDumpTransformer(aes, TransformEncrypt, key, columns)
+	if err := enc.Transform(strings.NewReader(sampleDump), &encrypted); err != nil {
+		t.Fatalf("Encrypt transform failed: %v", err)
+	}
+
+	out := encrypted.String()
+	if strings.Contains(out, "alice@example.com") {
+		t.Error("Expected email to be encrypted")
+	}
+	if !strings.Contains(out, "'it\\'s, (fine)'") {
+		t.Error("Expected untargeted column to be copied unchanged")
+	}
+	if !strings.Contains(out, "INSERT INTO `other` VALUES (1,'untouched');") {
+		t.Error("Expected untargeted table to be copied unchanged")
+	}
+	if !strings.Contains(out, ",NULL,0x") {
+		t.Error("Expected NULL to be kept and hex literal to stay a hex literal")
+	}
+
+	expected, err := aes.EncryptString("alice@example.com", key)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if !strings.Contains(out, "'"+expected+"'") {
+		t.Errorf("Expected encrypted email %q in output", expected)
+	}
+
+	var decrypted bytes.Buffer
+	dec := NewDumpTransformer(aes, TransformDecrypt, key, columns)
+	if err := dec.Transform(strings.NewReader(out), &decrypted); err != nil {
+		t.Fatalf("Decrypt transform failed: %v", err)
+	}
+	if decrypted.String() != sampleDump {
+		t.Errorf("Round-trip mismatch:\nexpected:\n%s\ngot:\n%s", sampleDump, decrypted.String())
+	}
+}
+
+func TestDumpTransformer_CompleteInsert(t *testing.T) {
+	aes := New()
+	key := "dumpkey"
+	input := "INSERT INTO `users` (`note`, `email`) VALUES ('a','b@example.com');\n"
+
+	var out bytes.Buffer
+	tr := NewDumpTransformer(aes, TransformEncrypt, key, map[string][]string{"users": {"email"}})
+	if err := tr.Transform(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+
+	expected, _ := aes.EncryptString("b@example.com", key)
+	want := "INSERT INTO `users` (`note`, `email`) VALUES ('a','" + expected + "');\n"
+	if out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestDumpTransformer_MultiLineStatement(t *testing.T) {
+	aes := New()
+	key := "dumpkey"
+	input := "CREATE TABLE `t` (\n  `v` text\n);\nINSERT INTO `t` VALUES ('first\nsecond;'),\n('third');\n"
+
+	var encrypted, decrypted bytes.Buffer
+	columns := map[string][]string{"t": {"v"}}
+	if err := NewDumpTransformer(aes, TransformEncrypt, key, columns).Transform(strings.NewReader(input), &encrypted); err != nil {
+		t.Fatalf("Encrypt transform failed: %v", err)
+	}
+	if err := NewDumpTransformer(aes, TransformDecrypt, key, columns).Transform(&encrypted, &decrypted); err != nil {
+		t.Fatalf("Decrypt transform failed: %v", err)
+	}
+
+	// The raw newline inside the literal is re-escaped on output
+	want := "CREATE TABLE `t` (\n  `v` text\n);\nINSERT INTO `t` VALUES ('first\\nsecond;'),\n('third');\n"
+	if decrypted.String() != want {
+		t.Errorf("Expected %q, got %q", want, decrypted.String())
+	}
+}
+
+func TestStatementComplete(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"INSERT INTO `t` VALUES (1);", true},
+		{"INSERT INTO `t` VALUES ('a;", false},
+		{"INSERT INTO `t` VALUES ('it\\'s;'", false},
+		{"INSERT INTO `t` VALUES ('it\\'s');", true},
+		{"INSERT INTO `t` VALUES (\"a\\\";\");", true},
+		{"INSERT INTO `dir\\` VALUES (1);", true},
+		{"INSERT INTO `a``b;` VALUES (1)", false},
+		{"INSERT INTO `a``b` VALUES (1);", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := statementComplete(tc.input); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDumpTransformer_Errors(t *testing.T) {
+	aes := New()
+	columns := map[string][]string{"users": {"email"}}
+
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"unknown columns", "INSERT INTO `users` VALUES (1,'x');\n"},
+		{"bad ciphertext", "CREATE TABLE `users` (\n  `email` text\n);\nINSERT INTO `users` VALUES ('zz');\n"},
+		{"unterminated string", "CREATE TABLE `users` (\n  `email` text\n);\nINSERT INTO `users` VALUES ('abc"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := NewDumpTransformer(aes, TransformDecrypt, "key", columns).Transform(strings.NewReader(tc.input), &out)
+			if err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
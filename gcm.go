@@ -0,0 +1,132 @@
+package mysql_aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// GCMNonceSize is the size of the random nonce stored in front of AES-GCM ciphertext
+	GCMNonceSize = 12
+	// GCMTagSize is the size of the authentication tag stored after AES-GCM ciphertext
+	GCMTagSize = 16
+
+	// GCMHexPrefix marks hex encoded AES-GCM values. It contains a character that hex
+	// encoded MySQL-compatible ciphertext never has, so the two cannot be confused.
+	GCMHexPrefix = "gcm1:"
+	// GCMBase64Prefix marks base64 encoded AES-GCM values
+	GCMBase64Prefix = "gcm1b:"
+)
+
+// ErrAuthenticationFailed is returned when authenticated ciphertext fails verification:
+// the key or associated data is wrong, or the value was modified
+var ErrAuthenticationFailed = errors.New("message authentication failed")
+
+// newGCM creates an AES-GCM AEAD using the same key handling as Encrypt
+func (m *MySQLAES) newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	block, err := aes.NewCipher(aesKey(key, AESKeyLen/8))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
+
+// EncryptGCM encrypts plaintext with AES-GCM and a random nonce. The result is the nonce,
+// the ciphertext and the tag. additionalData is authenticated but not encrypted, and must be
+// passed again to DecryptGCM. The output cannot be decrypted by MySQL; use it for columns
+// that only Go reads.
+func (m *MySQLAES) EncryptGCM(plaintext, key, additionalData []byte) ([]byte, error) {
+	aead, err := m.newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, GCMNonceSize, GCMNonceSize+len(plaintext)+GCMTagSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// DecryptGCM decrypts and verifies a value produced by EncryptGCM
+func (m *MySQLAES) DecryptGCM(ciphertext, key, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < GCMNonceSize+GCMTagSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	aead, err := m.newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, ciphertext[:GCMNonceSize], ciphertext[GCMNonceSize:], additionalData)
+	if err != nil {
+		return nil, ErrAuthenticationFailed
+	}
+	if plaintext == nil {
+		plaintext = []byte{}
+	}
+	return plaintext, nil
+}
+
+// EncryptGCMString encrypts a string with AES-GCM and returns it hex encoded with GCMHexPrefix
+func (m *MySQLAES) EncryptGCMString(plaintext, key string, additionalData []byte) (string, error) {
+	encrypted, err := m.EncryptGCM([]byte(plaintext), []byte(key), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return GCMHexPrefix + hex.EncodeToString(encrypted), nil
+}
+
+// EncryptGCMBase64 encrypts a string with AES-GCM and returns it base64 encoded with GCMBase64Prefix
+func (m *MySQLAES) EncryptGCMBase64(plaintext, key string, additionalData []byte) (string, error) {
+	encrypted, err := m.EncryptGCM([]byte(plaintext), []byte(key), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return GCMBase64Prefix + base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// DecryptGCMString decrypts a value from EncryptGCMString or EncryptGCMBase64
+func (m *MySQLAES) DecryptGCMString(value, key string, additionalData []byte) (string, error) {
+	var ciphertext []byte
+	var err error
+	switch {
+	case strings.HasPrefix(value, GCMHexPrefix):
+		ciphertext, err = hex.DecodeString(value[len(GCMHexPrefix):])
+		if err != nil {
+			return "", fmt.Errorf("invalid hex string: %w", err)
+		}
+	case strings.HasPrefix(value, GCMBase64Prefix):
+		ciphertext, err = decodeBase64(value[len(GCMBase64Prefix):])
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("value is not AES-GCM ciphertext")
+	}
+
+	decrypted, err := m.DecryptGCM(ciphertext, []byte(key), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// IsGCM reports whether a string value carries an AES-GCM prefix
+func IsGCM(value string) bool {
+	return strings.HasPrefix(value, GCMHexPrefix) || strings.HasPrefix(value, GCMBase64Prefix)
+}
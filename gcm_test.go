@@ -0,0 +1,115 @@
+package mysql_aes
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMySQLAES_GCM(t *testing.T) {
+	aes := New()
+	key := "app-only-key"
+	ad := []byte("users.ssn")
+
+	testCases := []struct {
+		name    string
+		encrypt func(plaintext, key string, ad []byte) (string, error)
+		prefix  string
+	}{
+		{"hex", aes.EncryptGCMString, GCMHexPrefix},
+		{"base64", aes.EncryptGCMBase64, GCMBase64Prefix},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, plaintext := range []string{"123-45-6789", "", "Unicode: 你好世界 🌍"} {
+				encrypted, err := tc.encrypt(plaintext, key, ad)
+				if err != nil {
+					t.Fatalf("Encryption failed: %v", err)
+				}
+				if !strings.HasPrefix(encrypted, tc.prefix) || !IsGCM(encrypted) {
+					t.Errorf("Expected prefix %q, got %q", tc.prefix, encrypted)
+				}
+
+				decrypted, err := aes.DecryptGCMString(encrypted, key, ad)
+				if err != nil {
+					t.Fatalf("Decryption failed: %v", err)
+				}
+				if decrypted != plaintext {
+					t.Errorf("Expected %q, got %q", plaintext, decrypted)
+				}
+			}
+		})
+	}
+}
+
+func TestMySQLAES_GCMRandomNonce(t *testing.T) {
+	aes := New()
+	a, _ := aes.EncryptGCMString("same", "key", nil)
+	b, _ := aes.EncryptGCMString("same", "key", nil)
+	if a == b {
+		t.Error("Expected different ciphertexts for the same plaintext")
+	}
+}
+
+func TestMySQLAES_GCMAuthentication(t *testing.T) {
+	aes := New()
+	key := "app-only-key"
+	encrypted, err := aes.EncryptGCMString("secret", key, []byte("row:1"))
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	tampered := []byte(encrypted)
+	last := len(tampered) - 1
+	if tampered[last] == '0' {
+		tampered[last] = '1'
+	} else {
+		tampered[last] = '0'
+	}
+
+	testCases := []struct {
+		name  string
+		value string
+		key   string
+		ad    string
+	}{
+		{"wrong key", encrypted, "other-key", "row:1"},
+		{"wrong associated data", encrypted, key, "row:2"},
+		{"tampered", string(tampered), key, "row:1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := aes.DecryptGCMString(tc.value, tc.key, []byte(tc.ad))
+			if !errors.Is(err, ErrAuthenticationFailed) {
+				t.Errorf("Expected ErrAuthenticationFailed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMySQLAES_GCMNotConfused(t *testing.T) {
+	aes := New()
+	key := "key"
+
+	gcm, _ := aes.EncryptGCMString("secret", key, nil)
+	if _, err := aes.DecryptString(gcm, key); err == nil {
+		t.Error("Expected DecryptString to reject AES-GCM values")
+	}
+
+	ecb, _ := aes.EncryptString("secret", key)
+	if IsGCM(ecb) {
+		t.Error("Expected MySQL-compatible ciphertext not to be detected as AES-GCM")
+	}
+	if _, err := aes.DecryptGCMString(ecb, key, nil); err == nil {
+		t.Error("Expected DecryptGCMString to reject MySQL-compatible ciphertext")
+	}
+
+	if _, err := aes.DecryptGCM([]byte("short"), []byte(key), nil); err == nil {
+		t.Error("Expected error for short ciphertext")
+	}
+	if _, err := aes.EncryptGCM([]byte("x"), nil, nil); err == nil {
+		t.Error("Expected error for empty key")
+	}
+}
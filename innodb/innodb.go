@@ -0,0 +1,334 @@
+// Package innodb decrypts the pages of InnoDB tablespaces created with ENCRYPTION='Y', for
+// reading .ibd files offline.
+//
+// Page 0 of an encrypted tablespace holds the tablespace key and IV, encrypted with
+// AES-256-ECB under a master key from the keyring and followed by a CRC-32C of the plain key
+// and IV. Every other page keeps its 38-byte header in the clear and has the rest encrypted
+// with AES-256-CBC under the tablespace key.
+//
+// Pages of tables that also use transparent page compression (COMPRESSION='zlib' or 'lz4')
+// are compressed first, and only the compressed data is encrypted. Decryption leaves them as
+// compressed pages, as the server does before it uncompresses them.
+package innodb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+
+	"github.com/ace3/mysql-aes"
+)
+
+// Page header and FSP header offsets
+const (
+	filPageSpaceOrChecksum = 0
+	filPageOffset          = 4
+	filPageType            = 24
+	filPageFileFlushLSN    = 26
+	filPageVersion         = 26
+	filPageOriginalType    = 28
+	filPageCompressSize    = 32
+	filPageData            = 38
+	filPageTrailer         = 8
+
+	fspSpaceFlags = filPageData + 16
+	xdesArrOffset = filPageData + 112
+	xdesBitmap    = 24
+)
+
+// Page types involved in encryption
+const (
+	pageTypeCompressed          = 14
+	pageTypeEncrypted           = 15
+	pageTypeCompressedEncrypted = 16
+	pageTypeEncryptedRTree      = 17
+)
+
+// FSP flags and page sizes
+const (
+	fspFlagsPosZipSSize   = 1
+	fspFlagsMaskZipSSize  = 0xf << fspFlagsPosZipSSize
+	fspFlagsPosPageSSize  = 6
+	fspFlagsMaskPageSSize = 0xf << fspFlagsPosPageSSize
+	fspFlagsEncryption    = 1 << 13
+
+	defaultPageSize = 16384
+	extentSizeBytes = 1 << 20
+	minExtentPages  = 64
+)
+
+// Encryption info layout
+const (
+	encryptionMagicV1 = "lCA"
+	encryptionMagicV2 = "lCB"
+	encryptionMagicV3 = "lCC"
+	serverUUIDLen     = 36
+	tablespaceKeyLen  = 32
+	masterKeyPrefix   = "INNODBKey"
+	// encryptionInfoSize covers the magic, master key ID, server UUID, wrapped key and IV and checksum
+	encryptionInfoSize = 3 + 4 + serverUUIDLen + 2*tablespaceKeyLen + 4
+	// encryptionInfoMaxSize allows for MySQL 5.7, which stores the master key ID in 8 bytes
+	encryptionInfoMaxSize = encryptionInfoSize + 4
+)
+
+// Transparent page compression
+const (
+	compressionVersion1 = 1
+	// compressionBlockSize is the file system block size that version 1 compressed pages
+	// are encrypted up to. The server takes it from the data directory's file system; this
+	// package assumes the usual 4096.
+	compressionBlockSize = 4096
+	// minEncryptionLen is the shortest page prefix the server encrypts
+	minEncryptionLen = 2*aes.BlockSize + filPageData
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrNotEncrypted is returned for a tablespace whose page 0 has no encryption flag
+var ErrNotEncrypted = errors.New("tablespace is not encrypted")
+
+// EncryptionInfo is the tablespace key information stored in page 0
+type EncryptionInfo struct {
+	// Version is 1, 2 or 3 for the lCA, lCB and lCC formats
+	Version     int
+	MasterKeyID uint32
+	ServerUUID  string
+	Key         []byte
+	IV          []byte
+}
+
+// MasterKeyName returns the keyring ID of the master key that wraps the tablespace key
+func (e *EncryptionInfo) MasterKeyName() string {
+	return masterKeyPrefix + "-" + e.ServerUUID + "-" + strconv.FormatUint(uint64(e.MasterKeyID), 10)
+}
+
+// PageSize returns the page size recorded in the FSP flags of page 0
+func PageSize(page0 []byte) (int, error) {
+	if len(page0) < fspSpaceFlags+4 {
+		return 0, fmt.Errorf("page 0 is truncated")
+	}
+	flags := binary.BigEndian.Uint32(page0[fspSpaceFlags:])
+	ssize := (flags & fspFlagsMaskPageSSize) >> fspFlagsPosPageSSize
+	if ssize == 0 {
+		return defaultPageSize, nil
+	}
+	if ssize < 3 || ssize > 7 {
+		return 0, fmt.Errorf("invalid page size flag %d", ssize)
+	}
+	return 512 << ssize, nil
+}
+
+// encryptionInfoOffset returns where the encryption info follows the extent descriptors
+func encryptionInfoOffset(pageSize int) int {
+	extentPages := extentSizeBytes / pageSize
+	if pageSize > defaultPageSize {
+		extentPages = minExtentPages
+	}
+	xdesSize := xdesBitmap + (extentPages*2+7)/8
+	return xdesArrOffset + xdesSize*(pageSize/extentPages)
+}
+
+// ReadEncryptionInfo reads the encryption info from page 0 and unwraps the tablespace key
+// with the master key from ks
+func ReadEncryptionInfo(page0 []byte, ks mysql_aes.KeySource) (*EncryptionInfo, error) {
+	pageSize, err := PageSize(page0)
+	if err != nil {
+		return nil, err
+	}
+	if len(page0) < pageSize {
+		return nil, fmt.Errorf("page 0 is truncated")
+	}
+	flags := binary.BigEndian.Uint32(page0[fspSpaceFlags:])
+	if flags&fspFlagsEncryption == 0 {
+		return nil, ErrNotEncrypted
+	}
+
+	info := page0[encryptionInfoOffset(pageSize):]
+	e := &EncryptionInfo{}
+	switch string(info[:3]) {
+	case encryptionMagicV1:
+		return nil, fmt.Errorf("version 1 encryption info (MySQL 5.7.11) is not supported")
+	case encryptionMagicV2:
+		e.Version = 2
+	case encryptionMagicV3:
+		e.Version = 3
+	default:
+		return nil, fmt.Errorf("no encryption info in page 0")
+	}
+	e.MasterKeyID = binary.BigEndian.Uint32(info[3:])
+	info = info[7:]
+	// MySQL 5.7 wrote the key ID as an 8-byte word; the UUID never starts with zero bytes
+	if e.Version == 2 && binary.BigEndian.Uint32(info) == 0 {
+		info = info[4:]
+	}
+	e.ServerUUID = string(info[:serverUUIDLen])
+	wrapped := info[serverUUIDLen : serverUUIDLen+2*tablespaceKeyLen]
+	checksum := binary.BigEndian.Uint32(info[serverUUIDLen+2*tablespaceKeyLen:])
+
+	masterKey, err := ks.Key(e.MasterKeyName())
+	if err != nil {
+		return nil, err
+	}
+	if len(masterKey) != tablespaceKeyLen {
+		return nil, fmt.Errorf("master key %s must be %d bytes, got %d", e.MasterKeyName(), tablespaceKeyLen, len(masterKey))
+	}
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(wrapped))
+	for i := 0; i < len(wrapped); i += aes.BlockSize {
+		block.Decrypt(plain[i:i+aes.BlockSize], wrapped[i:i+aes.BlockSize])
+	}
+	if crc32.Checksum(plain, crc32c) != checksum {
+		return nil, fmt.Errorf("tablespace key checksum mismatch: wrong master key %s", e.MasterKeyName())
+	}
+	e.Key = plain[:tablespaceKeyLen]
+	e.IV = plain[tablespaceKeyLen:]
+	return e, nil
+}
+
+// Decrypter decrypts the pages of one tablespace
+type Decrypter struct {
+	pageSize int
+	block    cipher.Block
+	iv       []byte
+}
+
+// NewDecrypter reads the tablespace key from page 0 and prepares page decryption
+func NewDecrypter(page0 []byte, ks mysql_aes.KeySource) (*Decrypter, error) {
+	pageSize, err := PageSize(page0)
+	if err != nil {
+		return nil, err
+	}
+	flags := binary.BigEndian.Uint32(page0[fspSpaceFlags:])
+	if flags&fspFlagsMaskZipSSize != 0 {
+		return nil, fmt.Errorf("compressed (ROW_FORMAT=COMPRESSED) tablespaces are not supported")
+	}
+	info, err := ReadEncryptionInfo(page0, ks)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(info.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &Decrypter{pageSize: pageSize, block: block, iv: info.IV[:aes.BlockSize]}, nil
+}
+
+// PageSize returns the tablespace page size
+func (d *Decrypter) PageSize() int {
+	return d.pageSize
+}
+
+// DecryptPage decrypts an encrypted page in place and restores its original page type.
+// Compressed pages get the compressed page type instead. Pages that are not encrypted are
+// left unchanged.
+func (d *Decrypter) DecryptPage(page []byte) error {
+	if len(page) != d.pageSize {
+		return fmt.Errorf("page must be %d bytes, got %d", d.pageSize, len(page))
+	}
+	pageType := binary.BigEndian.Uint16(page[filPageType:])
+	data := page[filPageData:]
+	switch pageType {
+	case pageTypeEncrypted, pageTypeEncryptedRTree:
+	case pageTypeCompressedEncrypted:
+		// Only the compressed data is encrypted, padded to the block size
+		n := filPageData + int(binary.BigEndian.Uint16(page[filPageCompressSize:]))
+		if page[filPageVersion] == compressionVersion1 {
+			n = (n + compressionBlockSize - 1) / compressionBlockSize * compressionBlockSize
+		} else if n < minEncryptionLen {
+			n = minEncryptionLen
+		}
+		if n > len(page) {
+			return fmt.Errorf("page %d: compressed size %d exceeds the page",
+				binary.BigEndian.Uint32(page[filPageOffset:]), n)
+		}
+		data = page[filPageData:n]
+	default:
+		return nil
+	}
+
+	// The data is encrypted in whole blocks; when a tail remains, the last two blocks,
+	// which include it, were encrypted again separately
+	mainLen := len(data) / aes.BlockSize * aes.BlockSize
+	if mainLen != len(data) {
+		tail := data[len(data)-2*aes.BlockSize:]
+		cipher.NewCBCDecrypter(d.block, d.iv).CryptBlocks(tail, tail)
+	}
+	cipher.NewCBCDecrypter(d.block, d.iv).CryptBlocks(data[:mainLen], data[:mainLen])
+
+	if pageType == pageTypeCompressedEncrypted {
+		binary.BigEndian.PutUint16(page[filPageType:], pageTypeCompressed)
+		return nil
+	}
+	originalType := binary.BigEndian.Uint16(page[filPageOriginalType:])
+	binary.BigEndian.PutUint16(page[filPageType:], originalType)
+	binary.BigEndian.PutUint16(page[filPageOriginalType:], 0)
+	return nil
+}
+
+// DecryptTablespace copies an encrypted tablespace from r to w with every page decrypted.
+// Page 0 has its encryption flag and key information cleared and its checksum recomputed,
+// so the output reads as an unencrypted tablespace.
+func DecryptTablespace(r io.Reader, w io.Writer, ks mysql_aes.KeySource) error {
+	head := make([]byte, fspSpaceFlags+4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return fmt.Errorf("failed to read page 0: %w", err)
+	}
+	pageSize, err := PageSize(head)
+	if err != nil {
+		return err
+	}
+	page0 := make([]byte, pageSize)
+	copy(page0, head)
+	if _, err := io.ReadFull(r, page0[len(head):]); err != nil {
+		return fmt.Errorf("failed to read page 0: %w", err)
+	}
+
+	d, err := NewDecrypter(page0, ks)
+	if err != nil {
+		return err
+	}
+	clearEncryption(page0)
+	if _, err := w.Write(page0); err != nil {
+		return err
+	}
+
+	page := make([]byte, pageSize)
+	for n := 1; ; n++ {
+		if _, err := io.ReadFull(r, page); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read page %d: %w", n, err)
+		}
+		if err := d.DecryptPage(page); err != nil {
+			return err
+		}
+		if _, err := w.Write(page); err != nil {
+			return err
+		}
+	}
+}
+
+// clearEncryption removes the encryption flag and key information from page 0
+func clearEncryption(page0 []byte) {
+	flags := binary.BigEndian.Uint32(page0[fspSpaceFlags:])
+	binary.BigEndian.PutUint32(page0[fspSpaceFlags:], flags&^fspFlagsEncryption)
+	info := page0[encryptionInfoOffset(len(page0)):]
+	copy(info[:encryptionInfoMaxSize], make([]byte, encryptionInfoMaxSize))
+	setChecksum(page0)
+}
+
+// setChecksum stores the CRC-32C page checksum in the header and trailer
+func setChecksum(page []byte) {
+	sum := crc32.Checksum(page[filPageOffset:filPageFileFlushLSN], crc32c) ^
+		crc32.Checksum(page[filPageData:len(page)-filPageTrailer], crc32c)
+	binary.BigEndian.PutUint32(page[filPageSpaceOrChecksum:], sum)
+	binary.BigEndian.PutUint32(page[len(page)-filPageTrailer:], sum)
+}
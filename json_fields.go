@@ -0,0 +1,341 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonLegKind identifies one step of a MySQL JSON path
+type jsonLegKind int
+
+const (
+	jsonMember jsonLegKind = iota
+	jsonAnyMember
+	jsonIndex
+	jsonAnyIndex
+	jsonLastIndex
+)
+
+// jsonLeg is one parsed step of a MySQL JSON path such as .name, [3], [*] or .*
+type jsonLeg struct {
+	kind  jsonLegKind
+	name  string
+	index int
+}
+
+// parseJSONPath parses the MySQL JSON path subset used for field encryption:
+// $, .key, ."quoted key", .*, [N], [last] and [*]. The ** wildcard and ranges are not supported.
+func parseJSONPath(path string) ([]jsonLeg, error) {
+	s := strings.TrimSpace(path)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("invalid JSON path %q: must start with $", path)
+	}
+	s = s[1:]
+
+	var legs []jsonLeg
+	for len(s) > 0 {
+		switch s[0] {
+		case ' ', '\t':
+			s = s[1:]
+		case '.':
+			s = strings.TrimLeft(s[1:], " \t")
+			switch {
+			case strings.HasPrefix(s, "*"):
+				legs = append(legs, jsonLeg{kind: jsonAnyMember})
+				s = s[1:]
+			case strings.HasPrefix(s, `"`):
+				// A quoted key is a JSON string literal
+				end := 1
+				for end < len(s) && s[end] != '"' {
+					if s[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(s) {
+					return nil, fmt.Errorf("invalid JSON path %q: unterminated key", path)
+				}
+				name, err := strconv.Unquote(s[:end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSON path %q: %w", path, err)
+				}
+				legs = append(legs, jsonLeg{kind: jsonMember, name: name})
+				s = s[end+1:]
+			default:
+				end := 0
+				for end < len(s) && (isWordChar(s[end]) || s[end] >= 0x80) {
+					end++
+				}
+				if end == 0 {
+					return nil, fmt.Errorf("invalid JSON path %q: expected key name", path)
+				}
+				legs = append(legs, jsonLeg{kind: jsonMember, name: s[:end]})
+				s = s[end:]
+			}
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated array index", path)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			switch {
+			case inner == "*":
+				legs = append(legs, jsonLeg{kind: jsonAnyIndex})
+			case inner == "last":
+				legs = append(legs, jsonLeg{kind: jsonLastIndex})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid JSON path %q: unsupported array index %q", path, inner)
+				}
+				legs = append(legs, jsonLeg{kind: jsonIndex, index: n})
+			}
+			s = s[end+1:]
+		case '*':
+			return nil, fmt.Errorf("invalid JSON path %q: ** is not supported", path)
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", path, s[0])
+		}
+	}
+	return legs, nil
+}
+
+// hasWildcard reports whether a parsed path can match more than one value
+func hasWildcard(legs []jsonLeg) bool {
+	for _, leg := range legs {
+		if leg.kind == jsonAnyMember || leg.kind == jsonAnyIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// applyJSONPath replaces every value matched by legs with fn's result. Paths that do not
+// exist in the document and JSON null values are left alone.
+func applyJSONPath(node interface{}, legs []jsonLeg, fn func(interface{}) (interface{}, error)) (interface{}, error) {
+	if len(legs) == 0 {
+		if node == nil {
+			return nil, nil
+		}
+		return fn(node)
+	}
+
+	leg, rest := legs[0], legs[1:]
+	switch v := node.(type) {
+	case map[string]interface{}:
+		switch leg.kind {
+		case jsonMember:
+			if child, ok := v[leg.name]; ok {
+				updated, err := applyJSONPath(child, rest, fn)
+				if err != nil {
+					return nil, err
+				}
+				v[leg.name] = updated
+			}
+		case jsonAnyMember:
+			for name, child := range v {
+				updated, err := applyJSONPath(child, rest, fn)
+				if err != nil {
+					return nil, err
+				}
+				v[name] = updated
+			}
+		}
+	case []interface{}:
+		switch leg.kind {
+		case jsonIndex, jsonLastIndex:
+			i := leg.index
+			if leg.kind == jsonLastIndex {
+				i = len(v) - 1
+			}
+			if i >= 0 && i < len(v) {
+				updated, err := applyJSONPath(v[i], rest, fn)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = updated
+			}
+		case jsonAnyIndex:
+			for i := range v {
+				updated, err := applyJSONPath(v[i], rest, fn)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = updated
+			}
+		}
+	default:
+		// MySQL treats a scalar as a single element array for [0] and [last]
+		if (leg.kind == jsonIndex && leg.index == 0) || leg.kind == jsonLastIndex {
+			return applyJSONPath(node, rest, fn)
+		}
+	}
+	return node, nil
+}
+
+// transformJSONPaths parses doc, applies fn at every path and serializes the result
+func transformJSONPaths(doc []byte, paths []string, fn func(interface{}) (interface{}, error)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var root interface{}
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid JSON document: %w", err)
+	}
+
+	for _, path := range paths {
+		legs, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if root, err = applyJSONPath(root, legs, fn); err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// EncryptJSONPaths encrypts the values at the given MySQL JSON paths (such as $.ssn or
+// $.contacts[*].phone) and replaces them with EncryptString hex strings. Strings are encrypted
+// without their quotes and other values as their MySQL JSON text, the same input
+// JSON_UNQUOTE(JSON_EXTRACT(doc, path)) gives AES_ENCRYPT. Missing paths, nulls and empty
+// strings are skipped.
+func (m *MySQLAES) EncryptJSONPaths(doc []byte, paths []string, key string) ([]byte, error) {
+	return transformJSONPaths(doc, paths, func(v interface{}) (interface{}, error) {
+		if v == "" {
+			return v, nil
+		}
+		return m.EncryptString(mysqlJSONUnquote(v), key)
+	})
+}
+
+// DecryptJSONPaths decrypts the values at the given MySQL JSON paths. Decrypted values are
+// stored as JSON strings, as AES_DECRYPT returns them in SQL.
+func (m *MySQLAES) DecryptJSONPaths(doc []byte, paths []string, key string) ([]byte, error) {
+	return transformJSONPaths(doc, paths, func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("encrypted value must be a string")
+		}
+		if s == "" {
+			return s, nil
+		}
+		return m.DecryptString(s, key)
+	})
+}
+
+// JSONEncryptSQL returns the JSON_REPLACE expression that encrypts the given paths of
+// docExpr in SQL, matching EncryptJSONPaths: missing paths are not created, and nulls and
+// empty strings are kept as they are. keyExpr is the SQL key expression, such as 'mykey' or
+// a placeholder. Paths with wildcards are rejected because JSON_REPLACE cannot use them.
+func JSONEncryptSQL(docExpr string, paths []string, keyExpr string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("at least one path is required")
+	}
+	var b strings.Builder
+	b.WriteString("JSON_REPLACE(")
+	b.WriteString(docExpr)
+	for _, path := range paths {
+		if err := checkSQLPath(path); err != nil {
+			return "", err
+		}
+		literal := "'" + escapeSQLString([]byte(path)) + "'"
+		extract := fmt.Sprintf("JSON_EXTRACT(%s, %s)", docExpr, literal)
+		// Both IF branches are JSON; mixed with a string the IF would return a string
+		fmt.Fprintf(&b, ", %s, IF(JSON_CONTAINS_PATH(%s, 'one', %s) AND JSON_TYPE(%s) <> 'NULL' AND JSON_UNQUOTE(%s) <> '', CAST(JSON_QUOTE(HEX(AES_ENCRYPT(JSON_UNQUOTE(%s), %s))) AS JSON), %s)",
+			literal, docExpr, literal, extract, extract, extract, keyExpr, extract)
+	}
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// JSONDecryptSQL returns the expression that decrypts one path of docExpr in SQL
+func JSONDecryptSQL(docExpr, path, keyExpr string) (string, error) {
+	if err := checkSQLPath(path); err != nil {
+		return "", err
+	}
+	literal := "'" + escapeSQLString([]byte(path)) + "'"
+	return fmt.Sprintf("AES_DECRYPT(UNHEX(JSON_UNQUOTE(JSON_EXTRACT(%s, %s))), %s)", docExpr, literal, keyExpr), nil
+}
+
+// checkSQLPath validates a path for use in generated SQL
+func checkSQLPath(path string) error {
+	legs, err := parseJSONPath(path)
+	if err != nil {
+		return err
+	}
+	if hasWildcard(legs) {
+		return fmt.Errorf("path %s: wildcards cannot be used in JSON_REPLACE; expand them to concrete paths", path)
+	}
+	return nil
+}
+
+// mysqlJSONUnquote returns what JSON_UNQUOTE gives for a JSON value: strings without quotes,
+// everything else as MySQL's JSON text
+func mysqlJSONUnquote(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var b strings.Builder
+	writeMySQLJSON(&b, v)
+	return b.String()
+}
+
+// writeMySQLJSON writes v the way MySQL prints JSON values: keys ordered by length and then
+// bytes, with ", " and ": " separators
+func writeMySQLJSON(b *strings.Builder, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(val))
+	case json.Number:
+		b.WriteString(val.String())
+	case string:
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(val)
+		b.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeMySQLJSON(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeMySQLJSON(b, k)
+			b.WriteString(": ")
+			writeMySQLJSON(b, val[k])
+		}
+		b.WriteByte('}')
+	}
+}
@@ -0,0 +1,149 @@
+package mysql_aes
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sampleProfile = `{"name": "Alice", "nick": "", "ssn": "123-45-6789", "age": 42, "note": null,
+	"contacts": [{"type": "home", "phone": "+1 555 0100"}, {"type": "work", "phone": "+1 555 0199"}],
+	"address": {"zip": "12345", "geo": {"lat": 1.5, "lng": -2}}}`
+
+func TestMySQLAES_JSONPaths(t *testing.T) {
+	aes := New()
+	key := "json-key"
+	paths := []string{"$.ssn", "$.contacts[*].phone", "$.address.geo", "$.age", "$.note", "$.nick", "$.missing"}
+
+	encrypted, err := aes.EncryptJSONPaths([]byte(sampleProfile), paths, key)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(encrypted, &doc); err != nil {
+		t.Fatalf("Invalid JSON output: %v", err)
+	}
+	if doc["name"] != "Alice" || doc["note"] != nil || doc["nick"] != "" {
+		t.Errorf("Expected untargeted values to be unchanged: %s", encrypted)
+	}
+	if _, ok := doc["missing"]; ok {
+		t.Error("Expected missing paths not to be created")
+	}
+
+	expected, _ := aes.EncryptString("123-45-6789", key)
+	if doc["ssn"] != expected {
+		t.Errorf("Expected ssn %q, got %v", expected, doc["ssn"])
+	}
+	phone := doc["contacts"].([]interface{})[1].(map[string]interface{})["phone"]
+	if expected, _ := aes.EncryptString("+1 555 0199", key); phone != expected {
+		t.Errorf("Expected wildcard path to encrypt every phone, got %v", phone)
+	}
+	// Non-string values are encrypted as MySQL's JSON text
+	if expected, _ := aes.EncryptString(`{"lat": 1.5, "lng": -2}`, key); doc["address"].(map[string]interface{})["geo"] != expected {
+		t.Error("Expected object to be encrypted as MySQL JSON text")
+	}
+
+	decrypted, err := aes.DecryptJSONPaths(encrypted, paths[:2], key)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	var original, roundTrip map[string]interface{}
+	json.Unmarshal([]byte(sampleProfile), &original)
+	json.Unmarshal(decrypted, &roundTrip)
+	if !reflect.DeepEqual(original["contacts"], roundTrip["contacts"]) || roundTrip["ssn"] != "123-45-6789" {
+		t.Errorf("Round-trip mismatch: %s", decrypted)
+	}
+
+	// Decrypted non-strings come back as strings, like AES_DECRYPT in SQL
+	decrypted, _ = aes.DecryptJSONPaths(encrypted, []string{"$.age"}, key)
+	if !strings.Contains(string(decrypted), `"age":"42"`) {
+		t.Errorf("Expected age as a string, got %s", decrypted)
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	testCases := []struct {
+		path  string
+		legs  []jsonLeg
+		valid bool
+	}{
+		{"$", nil, true},
+		{"$.a.b", []jsonLeg{{kind: jsonMember, name: "a"}, {kind: jsonMember, name: "b"}}, true},
+		{`$."first name"`, []jsonLeg{{kind: jsonMember, name: "first name"}}, true},
+		{"$.a[2][last]", []jsonLeg{{kind: jsonMember, name: "a"}, {kind: jsonIndex, index: 2}, {kind: jsonLastIndex}}, true},
+		{"$.*[*]", []jsonLeg{{kind: jsonAnyMember}, {kind: jsonAnyIndex}}, true},
+		{"a.b", nil, false},
+		{"$**.a", nil, false},
+		{"$.a[1 to 3]", nil, false},
+		{`$."open`, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			legs, err := parseJSONPath(tc.path)
+			if (err == nil) != tc.valid {
+				t.Fatalf("Expected valid=%v, got error %v", tc.valid, err)
+			}
+			if tc.valid && !reflect.DeepEqual(legs, tc.legs) {
+				t.Errorf("Expected %+v, got %+v", tc.legs, legs)
+			}
+		})
+	}
+}
+
+func TestJSONSQL(t *testing.T) {
+	// Missing paths stay missing under JSON_REPLACE, and the IF keeps nulls and empty
+	// strings, as EncryptJSONPaths does
+	query, err := JSONEncryptSQL("profile", []string{"$.ssn"}, "'k'")
+	if err != nil {
+		t.Fatalf("JSONEncryptSQL failed: %v", err)
+	}
+	expected := "JSON_REPLACE(profile, '$.ssn', IF(JSON_CONTAINS_PATH(profile, 'one', '$.ssn') AND " +
+		"JSON_TYPE(JSON_EXTRACT(profile, '$.ssn')) <> 'NULL' AND JSON_UNQUOTE(JSON_EXTRACT(profile, '$.ssn')) <> '', " +
+		"CAST(JSON_QUOTE(HEX(AES_ENCRYPT(JSON_UNQUOTE(JSON_EXTRACT(profile, '$.ssn')), 'k'))) AS JSON), JSON_EXTRACT(profile, '$.ssn')))"
+	if query != expected {
+		t.Errorf("Expected %s, got %s", expected, query)
+	}
+
+	// Each path is guarded on its own, and quotes in paths are escaped
+	query, err = JSONEncryptSQL("profile", []string{"$.ssn", `$."it's"[0]`}, "?")
+	if err != nil {
+		t.Fatalf("JSONEncryptSQL failed: %v", err)
+	}
+	expected = "JSON_REPLACE(profile, '$.ssn', IF(JSON_CONTAINS_PATH(profile, 'one', '$.ssn') AND " +
+		"JSON_TYPE(JSON_EXTRACT(profile, '$.ssn')) <> 'NULL' AND JSON_UNQUOTE(JSON_EXTRACT(profile, '$.ssn')) <> '', " +
+		"CAST(JSON_QUOTE(HEX(AES_ENCRYPT(JSON_UNQUOTE(JSON_EXTRACT(profile, '$.ssn')), ?))) AS JSON), JSON_EXTRACT(profile, '$.ssn')), " +
+		`'$.\"it\'s\"[0]', IF(JSON_CONTAINS_PATH(profile, 'one', '$.\"it\'s\"[0]') AND ` +
+		`JSON_TYPE(JSON_EXTRACT(profile, '$.\"it\'s\"[0]')) <> 'NULL' AND JSON_UNQUOTE(JSON_EXTRACT(profile, '$.\"it\'s\"[0]')) <> '', ` +
+		`CAST(JSON_QUOTE(HEX(AES_ENCRYPT(JSON_UNQUOTE(JSON_EXTRACT(profile, '$.\"it\'s\"[0]')), ?))) AS JSON), JSON_EXTRACT(profile, '$.\"it\'s\"[0]')))`
+	if query != expected {
+		t.Errorf("Expected %s, got %s", expected, query)
+	}
+
+	query, err = JSONDecryptSQL("profile", "$.ssn", "'k'")
+	if err != nil || query != "AES_DECRYPT(UNHEX(JSON_UNQUOTE(JSON_EXTRACT(profile, '$.ssn'))), 'k')" {
+		t.Errorf("Unexpected decrypt SQL: %s, %v", query, err)
+	}
+
+	if _, err := JSONEncryptSQL("profile", []string{"$.contacts[*].phone"}, "'k'"); err == nil {
+		t.Error("Expected error for wildcard path")
+	}
+	if _, err := JSONEncryptSQL("profile", nil, "'k'"); err == nil {
+		t.Error("Expected error for no paths")
+	}
+}
+
+func TestMySQLAES_JSONErrors(t *testing.T) {
+	aes := New()
+	if _, err := aes.EncryptJSONPaths([]byte("{not json"), []string{"$.a"}, "k"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+	if _, err := aes.EncryptJSONPaths([]byte(`{"a": 1}`), []string{"a"}, "k"); err == nil {
+		t.Error("Expected error for invalid path")
+	}
+	if _, err := aes.DecryptJSONPaths([]byte(`{"a": 1}`), []string{"$.a"}, "k"); err == nil {
+		t.Error("Expected error decrypting a non-string value")
+	}
+}
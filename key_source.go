@@ -0,0 +1,44 @@
+package mysql_aes
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotFound is returned by a KeySource that has no key with the requested ID
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeySource looks up encryption keys by ID, such as the master keys held in a MySQL keyring
+type KeySource interface {
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeySource is a KeySource backed by a map of key IDs to keys
+type StaticKeySource map[string][]byte
+
+// Key returns the key with the given ID
+func (s StaticKeySource) Key(id string) ([]byte, error) {
+	key, ok := s[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// EncryptStringWithKeySource encrypts a string with the key keyID from ks, returning a hex string
+func (m *MySQLAES) EncryptStringWithKeySource(plaintext string, ks KeySource, keyID string) (string, error) {
+	key, err := ks.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	return m.EncryptString(plaintext, string(key))
+}
+
+// DecryptStringWithKeySource decrypts a hex string with the key keyID from ks
+func (m *MySQLAES) DecryptStringWithKeySource(ciphertextHex string, ks KeySource, keyID string) (string, error) {
+	key, err := ks.Key(keyID)
+	if err != nil {
+		return "", err
+	}
+	return m.DecryptString(ciphertextHex, string(key))
+}
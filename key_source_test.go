@@ -0,0 +1,32 @@
+package mysql_aes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKeySource(t *testing.T) {
+	aes := New()
+	ks := StaticKeySource{"k1": []byte("first key"), "k2": []byte("second key")}
+
+	encrypted, err := aes.EncryptStringWithKeySource("secret", ks, "k1")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	expected, _ := aes.EncryptString("secret", "first key")
+	if encrypted != expected {
+		t.Errorf("Expected %q, got %q", expected, encrypted)
+	}
+
+	decrypted, err := aes.DecryptStringWithKeySource(encrypted, ks, "k1")
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if decrypted != "secret" {
+		t.Errorf("Expected %q, got %q", "secret", decrypted)
+	}
+
+	if _, err := aes.EncryptStringWithKeySource("secret", ks, "missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
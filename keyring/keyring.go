@@ -0,0 +1,396 @@
+// Package keyring reads and writes the data files of MySQL's keyring_file and
+// keyring_encrypted_file plugins, and exposes their keys as a mysql_aes.KeySource.
+//
+// A keyring_file data file is the version header "Keyring file version:2.0", the serialized
+// keys, the tag "EOF" and the SHA-256 digest of the serialized keys. Version 1.0 files have
+// no digest. Each key is serialized as five 8-byte little-endian lengths (record size, ID,
+// type, user and key data) followed by the four fields, padded to a multiple of 8 bytes. Key
+// data is stored XORed with the plugin's fixed obfuscation string.
+//
+// A keyring_encrypted_file data file has the same header, then a 16-byte IV, the serialized
+// keys encrypted with AES-256-CBC under SHA-256 of the password, the "EOF" tag and the digest
+// of the serialized keys. keyring_encrypted_file is a MySQL Enterprise plugin, and this layout
+// has not been checked against a file it wrote.
+package keyring
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ace3/mysql-aes"
+)
+
+const (
+	// Version1 is the header of keyring files written by the MySQL 5.7 keyring_file plugin
+	Version1 = "Keyring file version:1.0"
+	// Version2 is the header of keyring files written by MySQL 8.0, which add a digest
+	Version2 = "Keyring file version:2.0"
+
+	eofTag     = "EOF"
+	lengthSize = 8
+	digestSize = sha256.Size
+)
+
+// obfuscation is XORed over key data in memory and on disk by the keyring plugins
+const obfuscation = "*305=Ljt0*!@$Hnm(*-9-w;:"
+
+// ErrWrongPassword is returned when an encrypted keyring does not decrypt with the password
+var ErrWrongPassword = errors.New("wrong keyring password")
+
+// Key is one keyring entry. System keys such as InnoDB and binary log master keys have an
+// empty User.
+type Key struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	User string `json:"user"`
+	Data []byte `json:"data"`
+}
+
+// Keyring is the decoded content of a keyring data file
+type Keyring struct {
+	Keys []Key
+}
+
+// Load reads a keyring_file data file
+func Load(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// LoadEncrypted reads a keyring_encrypted_file data file
+func LoadEncrypted(path, password string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEncrypted(data, password)
+}
+
+// Parse decodes a keyring_file data file. An empty file is an empty keyring.
+func Parse(data []byte) (*Keyring, error) {
+	if len(data) == 0 {
+		return &Keyring{}, nil
+	}
+	version, body, err := splitHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	if version == Version1 {
+		if !bytes.HasSuffix(body, []byte(eofTag)) {
+			return nil, fmt.Errorf("missing %s tag", eofTag)
+		}
+		buf = body[:len(body)-len(eofTag)]
+	} else {
+		var digest []byte
+		if buf, digest, err = splitTrailer(body); err != nil {
+			return nil, err
+		}
+		if sum := sha256.Sum256(buf); subtle.ConstantTimeCompare(sum[:], digest) != 1 {
+			return nil, fmt.Errorf("keyring digest mismatch")
+		}
+	}
+	return parseKeys(buf)
+}
+
+// ParseEncrypted decrypts and decodes a keyring_encrypted_file data file
+func ParseEncrypted(data []byte, password string) (*Keyring, error) {
+	if len(data) == 0 {
+		return &Keyring{}, nil
+	}
+	version, body, err := splitHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if version != Version2 {
+		return nil, fmt.Errorf("unsupported encrypted keyring version %q", version)
+	}
+	encrypted, digest, err := splitTrailer(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < 2*aes.BlockSize || len(encrypted)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted keyring length %d", len(encrypted))
+	}
+
+	block, err := aes.NewCipher(passwordKey(password))
+	if err != nil {
+		return nil, err
+	}
+	iv, buf := encrypted[:aes.BlockSize], make([]byte, len(encrypted)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(buf, encrypted[aes.BlockSize:])
+
+	// A wrong password shows up as bad padding or, failing that, a digest mismatch
+	pad := int(buf[len(buf)-1])
+	if pad == 0 || pad > aes.BlockSize || !bytes.Equal(buf[len(buf)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, ErrWrongPassword
+	}
+	buf = buf[:len(buf)-pad]
+	if sum := sha256.Sum256(buf); subtle.ConstantTimeCompare(sum[:], digest) != 1 {
+		return nil, ErrWrongPassword
+	}
+	return parseKeys(buf)
+}
+
+// Save writes the keyring as a version 2.0 keyring_file data file
+func (k *Keyring) Save(path string) error {
+	data, err := k.Marshal()
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// SaveEncrypted writes the keyring as a keyring_encrypted_file data file
+func (k *Keyring) SaveEncrypted(path, password string) error {
+	data, err := k.MarshalEncrypted(password)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data)
+}
+
+// Marshal encodes the keyring as a version 2.0 keyring_file data file
+func (k *Keyring) Marshal() ([]byte, error) {
+	buf, err := k.serialize()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf)
+	out := append([]byte(Version2), buf...)
+	out = append(out, eofTag...)
+	return append(out, sum[:]...), nil
+}
+
+// MarshalEncrypted encodes the keyring as a keyring_encrypted_file data file
+func (k *Keyring) MarshalEncrypted(password string) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+	buf, err := k.serialize()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(passwordKey(password))
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(buf)%aes.BlockSize
+	padded := append(append([]byte{}, buf...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	encrypted := make([]byte, aes.BlockSize+len(padded))
+	if _, err := io.ReadFull(rand.Reader, encrypted[:aes.BlockSize]); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+	cipher.NewCBCEncrypter(block, encrypted[:aes.BlockSize]).CryptBlocks(encrypted[aes.BlockSize:], padded)
+
+	sum := sha256.Sum256(buf)
+	out := append([]byte(Version2), encrypted...)
+	out = append(out, eofTag...)
+	return append(out, sum[:]...), nil
+}
+
+// Get returns the key with the given ID and user
+func (k *Keyring) Get(id, user string) (Key, bool) {
+	for _, key := range k.Keys {
+		if key.ID == id && key.User == user {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Key implements mysql_aes.KeySource. A system key (empty user) with the ID is preferred;
+// otherwise the ID must belong to exactly one user.
+func (k *Keyring) Key(id string) ([]byte, error) {
+	if key, ok := k.Get(id, ""); ok {
+		return key.Data, nil
+	}
+	var found *Key
+	for i := range k.Keys {
+		if k.Keys[i].ID == id {
+			if found != nil {
+				return nil, fmt.Errorf("key %s exists for several users", id)
+			}
+			found = &k.Keys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", mysql_aes.ErrKeyNotFound, id)
+	}
+	return found.Data, nil
+}
+
+// Add adds a key, rejecting duplicates of an existing ID and user as the plugins do
+func (k *Keyring) Add(key Key) error {
+	if key.ID == "" {
+		return fmt.Errorf("key ID cannot be empty")
+	}
+	if len(key.Data) == 0 {
+		return fmt.Errorf("key %s has no data", key.ID)
+	}
+	if _, ok := k.Get(key.ID, key.User); ok {
+		return fmt.Errorf("key %s already exists for user %q", key.ID, key.User)
+	}
+	k.Keys = append(k.Keys, key)
+	return nil
+}
+
+// Remove deletes the key with the given ID and user and reports whether it existed
+func (k *Keyring) Remove(id, user string) bool {
+	for i, key := range k.Keys {
+		if key.ID == id && key.User == user {
+			k.Keys = append(k.Keys[:i], k.Keys[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Export writes the keys as a JSON array with base64 key data. The output holds the keys in
+// the clear, so protect it like the keyring itself.
+func (k *Keyring) Export(w io.Writer) error {
+	keys := k.Keys
+	if keys == nil {
+		keys = []Key{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(keys)
+}
+
+// Import adds the keys from an Export document. Nothing is added if any key is invalid or
+// already present.
+func (k *Keyring) Import(r io.Reader) error {
+	var keys []Key
+	if err := json.NewDecoder(r).Decode(&keys); err != nil {
+		return fmt.Errorf("invalid key export: %w", err)
+	}
+	merged := &Keyring{Keys: append([]Key{}, k.Keys...)}
+	for _, key := range keys {
+		if err := merged.Add(key); err != nil {
+			return err
+		}
+	}
+	k.Keys = merged.Keys
+	return nil
+}
+
+// splitHeader separates the version header from the rest of the file
+func splitHeader(data []byte) (string, []byte, error) {
+	for _, version := range []string{Version1, Version2} {
+		if bytes.HasPrefix(data, []byte(version)) {
+			return version, data[len(version):], nil
+		}
+	}
+	return "", nil, fmt.Errorf("not a keyring file: unknown version header")
+}
+
+// splitTrailer separates the body of a version 2.0 file from its "EOF" tag and digest
+func splitTrailer(body []byte) ([]byte, []byte, error) {
+	if len(body) < len(eofTag)+digestSize {
+		return nil, nil, fmt.Errorf("keyring file is truncated")
+	}
+	digest := body[len(body)-digestSize:]
+	body = body[:len(body)-digestSize]
+	if !bytes.HasSuffix(body, []byte(eofTag)) {
+		return nil, nil, fmt.Errorf("missing %s tag", eofTag)
+	}
+	return body[:len(body)-len(eofTag)], digest, nil
+}
+
+// parseKeys decodes the serialized keys
+func parseKeys(buf []byte) (*Keyring, error) {
+	k := &Keyring{}
+	for pos := 0; pos < len(buf); {
+		if len(buf)-pos < 5*lengthSize {
+			return nil, fmt.Errorf("truncated key at offset %d", pos)
+		}
+		var lengths [5]uint64
+		for i := range lengths {
+			lengths[i] = binary.LittleEndian.Uint64(buf[pos+i*lengthSize:])
+		}
+		podSize := lengths[0]
+		fields := uint64(5 * lengthSize)
+		for _, n := range lengths[1:] {
+			fields += n
+		}
+		if podSize < fields || podSize%lengthSize != 0 || podSize > uint64(len(buf)-pos) {
+			return nil, fmt.Errorf("invalid key size at offset %d", pos)
+		}
+
+		field := pos + 5*lengthSize
+		next := func(n uint64) []byte {
+			f := buf[field : field+int(n)]
+			field += int(n)
+			return f
+		}
+		key := Key{
+			ID:   string(next(lengths[1])),
+			Type: string(next(lengths[2])),
+			User: string(next(lengths[3])),
+			Data: obfuscate(next(lengths[4])),
+		}
+		k.Keys = append(k.Keys, key)
+		pos += int(podSize)
+	}
+	return k, nil
+}
+
+// serialize encodes the keys in the plugin's in-memory layout
+func (k *Keyring) serialize() ([]byte, error) {
+	var buf []byte
+	for _, key := range k.Keys {
+		if key.ID == "" || len(key.Data) == 0 {
+			return nil, fmt.Errorf("key %q is incomplete", key.ID)
+		}
+		size := 5*lengthSize + len(key.ID) + len(key.Type) + len(key.User) + len(key.Data)
+		padding := (lengthSize - size%lengthSize) % lengthSize
+		for _, n := range []int{size + padding, len(key.ID), len(key.Type), len(key.User), len(key.Data)} {
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(n))
+		}
+		buf = append(buf, key.ID...)
+		buf = append(buf, key.Type...)
+		buf = append(buf, key.User...)
+		buf = append(buf, obfuscate(key.Data)...)
+		buf = append(buf, make([]byte, padding)...)
+	}
+	return buf, nil
+}
+
+// obfuscate XORs key data with the obfuscation string; it is its own inverse
+func obfuscate(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ obfuscation[i%len(obfuscation)]
+	}
+	return out
+}
+
+// passwordKey derives the AES-256 key of an encrypted keyring from its password
+func passwordKey(password string) []byte {
+	sum := sha256.Sum256([]byte(password))
+	return sum[:]
+}
+
+// writeFile writes a keyring with owner-only permissions
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
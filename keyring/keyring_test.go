@@ -0,0 +1,226 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ace3/mysql-aes"
+)
+
+// innodbKeyID is the form of the InnoDB master key IDs the server creates
+const innodbKeyID = "INNODBKey-9b9fb5e3-3fb3-11ee-9b4a-0242ac110002-1"
+
+// sampleKeys builds the serialized key of a keyring holding one InnoDB master key, laid out
+// field by field as the plugin stores it
+func sampleKeys(data []byte) []byte {
+	var buf []byte
+	size := 40 + len(innodbKeyID) + len("AES") + len(data)
+	padding := (8 - size%8) % 8
+	for _, n := range []int{size + padding, len(innodbKeyID), 3, 0, len(data)} {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(n))
+	}
+	buf = append(buf, innodbKeyID...)
+	buf = append(buf, "AES"...)
+	for i, b := range data {
+		buf = append(buf, b^"*305=Ljt0*!@$Hnm(*-9-w;:"[i%24])
+	}
+	return append(buf, make([]byte, padding)...)
+}
+
+func masterKey() []byte {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	return key
+}
+
+func TestParse_Version2(t *testing.T) {
+	keys := sampleKeys(masterKey())
+	sum := sha256.Sum256(keys)
+	file := append([]byte("Keyring file version:2.0"), keys...)
+	file = append(file, "EOF"...)
+	file = append(file, sum[:]...)
+
+	k, err := Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	key, ok := k.Get(innodbKeyID, "")
+	if !ok {
+		t.Fatal("Expected InnoDB master key")
+	}
+	if key.Type != "AES" || !bytes.Equal(key.Data, masterKey()) {
+		t.Errorf("Unexpected key %+v", key)
+	}
+
+	marshaled, err := k.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !bytes.Equal(marshaled, file) {
+		t.Error("Expected Marshal to reproduce the file byte for byte")
+	}
+
+	file[30] ^= 1
+	if _, err := Parse(file); err == nil {
+		t.Error("Expected digest mismatch for a modified file")
+	}
+}
+
+func TestParse_Version1(t *testing.T) {
+	file := append([]byte("Keyring file version:1.0"), sampleKeys(masterKey())...)
+	file = append(file, "EOF"...)
+
+	k, err := Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(k.Keys) != 1 || k.Keys[0].ID != innodbKeyID {
+		t.Errorf("Unexpected keys %+v", k.Keys)
+	}
+}
+
+// TestLoad_MySQL57 reads the keyring a MySQL 5.7.32 server wrote with keyring_file for an
+// encrypted table (data/keyring of mysql-test/std_data/data_57_32.zip). 5.7 writes version
+// 1.0 files without a digest.
+func TestLoad_MySQL57(t *testing.T) {
+	path := filepath.Join("testdata", "keyring_file_5.7.32")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(Version1)) {
+		t.Fatalf("Expected a %q header", Version1)
+	}
+
+	k, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(k.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(k.Keys))
+	}
+	key := k.Keys[0]
+	// The server UUID in auto.cnf of the same data directory is 7b6aa189-c52b-11ea-a75b-0010e0b6728a
+	if key.ID != "INNODBKey-7b6aa189-c52b-11ea-a75b-0010e0b6728a-1" || key.Type != "AES" || key.User != "" {
+		t.Errorf("Unexpected key %q/%q/%q", key.ID, key.Type, key.User)
+	}
+	expected := "46b4fa4c8c9d543acc94e998981d4ade585f2591493d6e7875c49568b92bb42a"
+	if got := hex.EncodeToString(key.Data); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown header", "not a keyring"},
+		{"truncated", "Keyring file version:2.0EOF"},
+		{"missing tag", "Keyring file version:1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	k, err := Parse(nil)
+	if err != nil || len(k.Keys) != 0 {
+		t.Errorf("Expected empty keyring for empty file, got %v, %v", k, err)
+	}
+}
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	k := &Keyring{}
+	if err := k.Add(Key{ID: innodbKeyID, Type: "AES", Data: masterKey()}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := k.Add(Key{ID: "app_key", Type: "SECRET", User: "root@localhost", Data: []byte("s3cret")}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "keyring-encrypted")
+	if err := k.SaveEncrypted(path, "keyring password"); err != nil {
+		t.Fatalf("SaveEncrypted failed: %v", err)
+	}
+
+	loaded, err := LoadEncrypted(path, "keyring password")
+	if err != nil {
+		t.Fatalf("LoadEncrypted failed: %v", err)
+	}
+	if len(loaded.Keys) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(loaded.Keys))
+	}
+	if key, _ := loaded.Get("app_key", "root@localhost"); string(key.Data) != "s3cret" {
+		t.Errorf("Expected %q, got %q", "s3cret", key.Data)
+	}
+
+	if _, err := LoadEncrypted(path, "wrong password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected plain parser to reject an encrypted keyring")
+	}
+}
+
+func TestKeyring_KeySource(t *testing.T) {
+	k := &Keyring{Keys: []Key{
+		{ID: innodbKeyID, Type: "AES", Data: masterKey()},
+		{ID: "shared", Type: "AES", User: "a@%", Data: []byte("a")},
+		{ID: "shared", Type: "AES", User: "b@%", Data: []byte("b")},
+	}}
+
+	var ks mysql_aes.KeySource = k
+	aes := mysql_aes.New()
+	encrypted, err := aes.EncryptStringWithKeySource("secret", ks, innodbKeyID)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	expected, _ := aes.EncryptString("secret", string(masterKey()))
+	if encrypted != expected {
+		t.Errorf("Expected %q, got %q", expected, encrypted)
+	}
+
+	if _, err := k.Key("shared"); err == nil {
+		t.Error("Expected error for an ID owned by several users")
+	}
+	if _, err := k.Key("missing"); !errors.Is(err, mysql_aes.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestKeyring_ExportImport(t *testing.T) {
+	src := &Keyring{Keys: []Key{{ID: innodbKeyID, Type: "AES", Data: masterKey()}}}
+	var buf bytes.Buffer
+	if err := src.Export(&buf); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	dst := &Keyring{Keys: []Key{{ID: "other", Type: "AES", Data: []byte("x")}}}
+	if err := dst.Import(strings.NewReader(buf.String())); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if key, ok := dst.Get(innodbKeyID, ""); !ok || !bytes.Equal(key.Data, masterKey()) {
+		t.Error("Expected imported key")
+	}
+
+	// Importing again fails without changing the keyring
+	if err := dst.Import(strings.NewReader(buf.String())); err == nil {
+		t.Error("Expected error for duplicate key")
+	}
+	if len(dst.Keys) != 2 {
+		t.Errorf("Expected 2 keys, got %d", len(dst.Keys))
+	}
+}
@@ -0,0 +1,245 @@
+package mysql_aes
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// desMarker is set in the first byte of every DES_ENCRYPT result
+	desMarker = 0x80
+	// desKeyStringNumber is the key number recorded when a key string was given
+	desKeyStringNumber = 127
+	// desPadding fills DES_ENCRYPT blocks before the final length byte
+	desPadding = '*'
+)
+
+// DESEncrypt encrypts like MySQL 5.x DES_ENCRYPT(str, key_str): a marker byte of 0xFF
+// followed by str, padded with '*' and a final pad length byte, encrypted with 3DES-CBC
+// under a zero IV. The 3DES key is derived from key_str with EVP_BytesToKey over MD5. An
+// empty str gives an empty result, as in MySQL.
+func DESEncrypt(plaintext []byte, key string) []byte {
+	return desEncrypt(plaintext, key, desKeyStringNumber)
+}
+
+// DESDecrypt decrypts like DES_DECRYPT(crypt_str, key_str). As in MySQL, values that do not
+// look like DES_ENCRYPT output are returned unchanged.
+func DESDecrypt(ciphertext []byte, key string) ([]byte, error) {
+	if !isDESEncrypted(ciphertext) {
+		return ciphertext, nil
+	}
+	return desDecrypt(ciphertext, key)
+}
+
+// isDESEncrypted reports whether DES_DECRYPT would decrypt a value rather than return it
+// unchanged: at least 9 bytes, one more than a multiple of 8, with the marker bit set
+func isDESEncrypted(ciphertext []byte) bool {
+	n := len(ciphertext)
+	return n >= 1+des.BlockSize && n%des.BlockSize == 1 && ciphertext[0]&desMarker != 0
+}
+
+// desBlock derives the 3DES cipher for a key string
+func desBlock(key string) cipher.Block {
+	// EVP_BytesToKey(des-ede3-cbc, md5, no salt, key, 1) for 24 key bytes; the IV it also
+	// derives is discarded by MySQL
+	var material []byte
+	var prev []byte
+	for len(material) < 24 {
+		d := md5.New()
+		d.Write(prev)
+		d.Write([]byte(key))
+		prev = d.Sum(nil)
+		material = append(material, prev...)
+	}
+	block, _ := des.NewTripleDESCipher(material[:24])
+	return block
+}
+
+// desEncrypt encrypts plaintext recording keyNumber in the marker byte
+func desEncrypt(plaintext []byte, key string, keyNumber int) []byte {
+	if len(plaintext) == 0 {
+		return []byte{}
+	}
+	tail := des.BlockSize - len(plaintext)%des.BlockSize
+	padded := make([]byte, len(plaintext)+tail)
+	copy(padded, plaintext)
+	for i := len(plaintext); i < len(padded)-1; i++ {
+		padded[i] = desPadding
+	}
+	padded[len(padded)-1] = byte(tail)
+
+	out := make([]byte, 1+len(padded))
+	out[0] = desMarker | byte(keyNumber)
+	iv := make([]byte, des.BlockSize)
+	cipher.NewCBCEncrypter(desBlock(key), iv).CryptBlocks(out[1:], padded)
+	return out
+}
+
+// desDecrypt decrypts a value accepted by isDESEncrypted with the key string
+func desDecrypt(ciphertext []byte, key string) ([]byte, error) {
+	data := ciphertext[1:]
+	out := make([]byte, len(data))
+	iv := make([]byte, des.BlockSize)
+	cipher.NewCBCDecrypter(desBlock(key), iv).CryptBlocks(out, data)
+
+	tail := int(out[len(out)-1])
+	if tail == 0 || tail > des.BlockSize {
+		return nil, fmt.Errorf("%w: wrong DES key", ErrNullResult)
+	}
+	return out[:len(out)-tail], nil
+}
+
+// DESKeyFile holds the numbered keys of a MySQL 5.x --des-key-file
+type DESKeyFile map[int]string
+
+// ParseDESKeyFile reads a des_key_file: lines of a key number 0-9, white space and the key
+// string. Lines that do not start with a digit are ignored, as the server does.
+func ParseDESKeyFile(r io.Reader) (DESKeyFile, error) {
+	keys := make(DESKeyFile)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] < '0' || line[0] > '9' {
+			continue
+		}
+		key := strings.TrimSpace(line[1:])
+		if key != "" {
+			keys[int(line[0]-'0')] = key
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Encrypt encrypts like DES_ENCRYPT(str, key_num)
+func (f DESKeyFile) Encrypt(plaintext []byte, keyNumber int) ([]byte, error) {
+	key, ok := f[keyNumber]
+	if !ok {
+		return nil, fmt.Errorf("DES key number %d is not in the key file", keyNumber)
+	}
+	return desEncrypt(plaintext, key, keyNumber), nil
+}
+
+// Decrypt decrypts like DES_DECRYPT(crypt_str), using the key number recorded in the value.
+// Values encrypted with a key string need DESDecrypt instead.
+func (f DESKeyFile) Decrypt(ciphertext []byte) ([]byte, error) {
+	if !isDESEncrypted(ciphertext) {
+		return ciphertext, nil
+	}
+	keyNumber := int(ciphertext[0] &^ desMarker)
+	if keyNumber == desKeyStringNumber {
+		return nil, fmt.Errorf("value was encrypted with a key string, use DESDecrypt")
+	}
+	key, ok := f[keyNumber]
+	if !ok {
+		return nil, fmt.Errorf("DES key number %d is not in the key file", keyNumber)
+	}
+	return desDecrypt(ciphertext, key)
+}
+
+// LegacyEncode encodes like the ENCODE(str, pass_str) function removed in MySQL 8.0
+func LegacyEncode(data []byte, password string) []byte {
+	c := newSQLCrypt(password)
+	out := make([]byte, len(data))
+	for i, b := range data {
+		c.shift ^= c.rand.next()
+		out[i] = c.encodeBuf[b] ^ byte(c.shift)
+		c.shift ^= uint32(b)
+	}
+	return out
+}
+
+// LegacyDecode decodes like DECODE(crypt_str, pass_str). Any input decodes, so a wrong
+// password gives garbage rather than an error, as in MySQL.
+func LegacyDecode(data []byte, password string) []byte {
+	c := newSQLCrypt(password)
+	out := make([]byte, len(data))
+	for i, b := range data {
+		c.shift ^= c.rand.next()
+		out[i] = c.decodeBuf[b^byte(c.shift)]
+		c.shift ^= uint32(out[i])
+	}
+	return out
+}
+
+// sqlCrypt is the state of MySQL's SQL_CRYPT class behind ENCODE and DECODE
+type sqlCrypt struct {
+	rand      *mysqlRand
+	encodeBuf [256]byte
+	decodeBuf [256]byte
+	shift     uint32
+}
+
+// newSQLCrypt seeds the generator from the pre-4.1 password hash and builds the
+// substitution tables
+func newSQLCrypt(password string) *sqlCrypt {
+	seed1, seed2 := oldPasswordHash(password)
+	c := &sqlCrypt{rand: newMySQLRand(seed1, seed2)}
+	for i := range c.decodeBuf {
+		c.decodeBuf[i] = byte(i)
+	}
+	for i := range c.decodeBuf {
+		idx := c.rand.next()
+		c.decodeBuf[idx], c.decodeBuf[i] = c.decodeBuf[i], c.decodeBuf[idx]
+	}
+	for i, b := range c.decodeBuf {
+		c.encodeBuf[b] = byte(i)
+	}
+	return c
+}
+
+// oldPasswordHash is MySQL's hash_password, used by OLD_PASSWORD and ENCODE. Spaces and
+// tabs are skipped. Only the low 31 bits are kept, which 32-bit arithmetic computes exactly.
+func oldPasswordHash(password string) (uint32, uint32) {
+	nr, add, nr2 := uint32(1345345333), uint32(7), uint32(0x12345671)
+	for i := 0; i < len(password); i++ {
+		c := password[i]
+		if c == ' ' || c == '\t' {
+			continue
+		}
+		tmp := uint32(c)
+		nr ^= ((nr&63)+add)*tmp + nr<<8
+		nr2 += nr2<<8 ^ nr
+		add += tmp
+	}
+	return nr & (1<<31 - 1), nr2 & (1<<31 - 1)
+}
+
+// mysqlRand is MySQL's my_rnd generator
+type mysqlRand struct {
+	seed1, seed2 uint64
+}
+
+// mysqlRandMax is the modulus of my_rnd
+const mysqlRandMax = 0x3FFFFFFF
+
+func newMySQLRand(seed1, seed2 uint32) *mysqlRand {
+	return &mysqlRand{seed1: uint64(seed1) % mysqlRandMax, seed2: uint64(seed2) % mysqlRandMax}
+}
+
+// next returns (uint)(my_rnd() * 255.0), the form SQL_CRYPT uses
+func (r *mysqlRand) next() uint32 {
+	r.seed1 = (r.seed1*3 + r.seed2) % mysqlRandMax
+	r.seed2 = (r.seed1 + r.seed2 + 33) % mysqlRandMax
+	return uint32(float64(r.seed1) / float64(mysqlRandMax) * 255.0)
+}
+
+// MigrateLegacy decodes a legacy value with decode and re-encrypts it like AES_ENCRYPT, for
+// moving DES_ENCRYPT or ENCODE columns to AES before upgrading to MySQL 8. For example:
+//
+//	m.MigrateLegacy(value, keyFile.Decrypt, aesKey)
+//	m.MigrateLegacy(value, func(v []byte) ([]byte, error) { return LegacyDecode(v, pass), nil }, aesKey)
+func (m *MySQLAES) MigrateLegacy(value []byte, decode func([]byte) ([]byte, error), key []byte) ([]byte, error) {
+	plaintext, err := decode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode legacy value: %w", err)
+	}
+	return m.encrypt(plaintext, key)
+}
@@ -0,0 +1,173 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDESEncrypt(t *testing.T) {
+	// 0xFF marker, then openssl enc -des-ede3-cbc -K <EVP_BytesToKey md5 of 'mykey'> -iv 0
+	// of 'hello**\x03'
+	encrypted := DESEncrypt([]byte("hello"), "mykey")
+	if got := hex.EncodeToString(encrypted); got != "ffbce372659b825862" {
+		t.Errorf("Expected %q, got %q", "ffbce372659b825862", got)
+	}
+
+	// SELECT HEX(DES_ENCRYPT('hello', 'default_password')) from MySQL 5.7's func_encrypt.result
+	if got := hex.EncodeToString(DESEncrypt([]byte("hello"), "default_password")); got != "ffd6dc8859f9759bbb" {
+		t.Errorf("Expected %q, got %q", "ffd6dc8859f9759bbb", got)
+	}
+
+	for _, plaintext := range []string{"a", "exactly8", "a longer value spanning blocks"} {
+		encrypted := DESEncrypt([]byte(plaintext), "mykey")
+		if (len(encrypted)-1)%8 != 0 || len(encrypted) <= len(plaintext) {
+			t.Errorf("Unexpected length %d for %q", len(encrypted), plaintext)
+		}
+		decrypted, err := DESDecrypt(encrypted, "mykey")
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if string(decrypted) != plaintext {
+			t.Errorf("Expected %q, got %q", plaintext, decrypted)
+		}
+	}
+
+	// DES_ENCRYPT('') is ''
+	if got := DESEncrypt(nil, "mykey"); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty result, got %x", got)
+	}
+
+	// Values without the marker bit or of the wrong length pass through unchanged
+	for _, value := range [][]byte{[]byte("plain"), []byte("\x80"), encrypted[:5], encrypted[:8], append(encrypted, 0), []byte("unmarked!")} {
+		got, err := DESDecrypt(value, "mykey")
+		if err != nil || !bytes.Equal(got, value) {
+			t.Errorf("Expected %x to pass through, got %x, %v", value, got, err)
+		}
+	}
+
+	if _, err := DESDecrypt(encrypted, "wrongkey"); !errors.Is(err, ErrNullResult) {
+		t.Errorf("Expected ErrNullResult for a wrong key, got %v", err)
+	}
+}
+
+func TestDESKeyFile(t *testing.T) {
+	keys, err := ParseDESKeyFile(strings.NewReader("# des keys\n0 first key\n5  fifth key  \nnot a key\n"))
+	if err != nil {
+		t.Fatalf("ParseDESKeyFile failed: %v", err)
+	}
+	if len(keys) != 2 || keys[5] != "fifth key" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+
+	encrypted, err := keys.Encrypt([]byte("secret"), 5)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if encrypted[0] != 0x85 {
+		t.Errorf("Expected marker 0x85, got 0x%02x", encrypted[0])
+	}
+	decrypted, err := keys.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if string(decrypted) != "secret" {
+		t.Errorf("Expected %q, got %q", "secret", decrypted)
+	}
+
+	// DES_DECRYPT(str, key_str) ignores the key number
+	if got, _ := DESDecrypt(encrypted, "fifth key"); string(got) != "secret" {
+		t.Errorf("Expected %q, got %q", "secret", got)
+	}
+	if got, err := keys.Decrypt(encrypted[:6]); err != nil || !bytes.Equal(got, encrypted[:6]) {
+		t.Errorf("Expected a truncated value to pass through, got %x, %v", got, err)
+	}
+	if _, err := keys.Decrypt(DESEncrypt([]byte("x"), "k")); err == nil {
+		t.Error("Expected error for a key string value")
+	}
+	if _, err := keys.Encrypt([]byte("x"), 3); err == nil {
+		t.Error("Expected error for a missing key number")
+	}
+}
+
+func TestOldPasswordHash(t *testing.T) {
+	// SELECT OLD_PASSWORD('mypass') from the MySQL manual
+	nr, nr2 := oldPasswordHash("mypass")
+	if got := fmt.Sprintf("%08x%08x", nr, nr2); got != "6f8c114b58f2ce9e" {
+		t.Errorf("Expected %q, got %q", "6f8c114b58f2ce9e", got)
+	}
+	// Spaces and tabs are ignored
+	a, b := oldPasswordHash("my pa\tss")
+	if a != nr || b != nr2 {
+		t.Error("Expected white space to be ignored")
+	}
+}
+
+func TestLegacyEncode(t *testing.T) {
+	// SELECT MD5(ENCODE('clear text to encode', 'password')) and
+	// MD5(DECODE('binary encoded data', 'password')) from MySQL 5.7's func_str.result
+	encodeSum := md5.Sum(LegacyEncode([]byte("clear text to encode"), "password"))
+	if got := hex.EncodeToString(encodeSum[:]); got != "44320fd2b4a0ec92faa2da2122def917" {
+		t.Errorf("Expected %q, got %q", "44320fd2b4a0ec92faa2da2122def917", got)
+	}
+	decodeSum := md5.Sum(LegacyDecode([]byte("binary encoded data"), "password"))
+	if got := hex.EncodeToString(decodeSum[:]); got != "5bea8c394368dbc03b76684483b7756b" {
+		t.Errorf("Expected %q, got %q", "5bea8c394368dbc03b76684483b7756b", got)
+	}
+
+	data := []byte("ENCODE() was removed in MySQL 8.0")
+	encoded := LegacyEncode(data, "pass")
+	if bytes.Equal(encoded, data) || len(encoded) != len(data) {
+		t.Errorf("Unexpected encoding %x", encoded)
+	}
+	if !bytes.Equal(LegacyEncode(data, "pass"), encoded) {
+		t.Error("Expected encoding to be deterministic")
+	}
+	if got := LegacyDecode(encoded, "pass"); !bytes.Equal(got, data) {
+		t.Errorf("Expected %q, got %q", data, got)
+	}
+	if got := LegacyDecode(encoded, "wrong"); bytes.Equal(got, data) {
+		t.Error("Expected a wrong password to give other data")
+	}
+
+	// Every byte value survives the substitution tables
+	all := make([]byte, 256)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	if got := LegacyDecode(LegacyEncode(all, "key"), "key"); !bytes.Equal(got, all) {
+		t.Error("Expected all byte values to round trip")
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	aes := New()
+	key := []byte("new aes key")
+
+	desValue := DESEncrypt([]byte("card 4111"), "old des key")
+	migrated, err := aes.MigrateLegacy(desValue, func(v []byte) ([]byte, error) { return DESDecrypt(v, "old des key") }, key)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	expected, _ := aes.Encrypt([]byte("card 4111"), key)
+	if !bytes.Equal(migrated, expected) {
+		t.Errorf("Expected %x, got %x", expected, migrated)
+	}
+
+	encoded := LegacyEncode([]byte("note"), "pw")
+	migrated, err = aes.MigrateLegacy(encoded, func(v []byte) ([]byte, error) { return LegacyDecode(v, "pw"), nil }, key)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if decrypted, _ := aes.Decrypt(migrated, key); string(decrypted) != "note" {
+		t.Errorf("Expected %q, got %q", "note", decrypted)
+	}
+
+	if _, err := aes.MigrateLegacy(desValue, func(v []byte) ([]byte, error) { return DESDecrypt(v, "wrong des key") }, key); err == nil {
+		t.Error("Expected decode errors to be returned")
+	}
+}
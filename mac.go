@@ -0,0 +1,127 @@
+package mysql_aes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// MACSize is the size in bytes of the HMAC-SHA256 integrity tag
+const MACSize = sha256.Size
+
+// ComputeMAC returns the HMAC-SHA256 tag of ciphertext
+func ComputeMAC(ciphertext, macKey []byte) []byte {
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(ciphertext)
+	return mac.Sum(nil)
+}
+
+// EncryptWithMAC encrypts plaintext like Encrypt and returns an HMAC-SHA256 tag of the
+// ciphertext. The ciphertext stays readable by AES_DECRYPT; the tag detects tampering.
+// macKey must be separate from the encryption key.
+func (m *MySQLAES) EncryptWithMAC(plaintext, key, macKey []byte) ([]byte, []byte, error) {
+	if len(macKey) == 0 {
+		return nil, nil, fmt.Errorf("MAC key cannot be empty")
+	}
+	ciphertext, err := m.Encrypt(plaintext, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, ComputeMAC(ciphertext, macKey), nil
+}
+
+// DecryptWithMAC verifies the tag in constant time and only then decrypts and unpads the
+// ciphertext, returning ErrAuthenticationFailed for a wrong tag
+func (m *MySQLAES) DecryptWithMAC(ciphertext, tag, key, macKey []byte) ([]byte, error) {
+	if len(macKey) == 0 {
+		return nil, fmt.Errorf("MAC key cannot be empty")
+	}
+	if !hmac.Equal(tag, ComputeMAC(ciphertext, macKey)) {
+		return nil, ErrAuthenticationFailed
+	}
+	return m.Decrypt(ciphertext, key)
+}
+
+// EncryptStringWithMAC encrypts a string and returns the hex ciphertext and the hex tag,
+// for storing the tag in a companion column
+func (m *MySQLAES) EncryptStringWithMAC(plaintext, key, macKey string) (string, string, error) {
+	ciphertext, tag, err := m.EncryptWithMAC([]byte(plaintext), []byte(key), []byte(macKey))
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(ciphertext), hex.EncodeToString(tag), nil
+}
+
+// DecryptStringWithMAC verifies and decrypts a hex ciphertext and its companion hex tag
+func (m *MySQLAES) DecryptStringWithMAC(ciphertextHex, tagHex, key, macKey string) (string, error) {
+	ciphertext, err := hex.DecodeString(ciphertextHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex string: %w", err)
+	}
+	tag, err := hex.DecodeString(tagHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex tag: %w", err)
+	}
+
+	decrypted, err := m.DecryptWithMAC(ciphertext, tag, []byte(key), []byte(macKey))
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// EncryptStringMACSuffix encrypts a string and returns the hex ciphertext with the hex tag
+// appended, for storing both in one column. MACSuffixSQL splits such values in SQL.
+func (m *MySQLAES) EncryptStringMACSuffix(plaintext, key, macKey string) (string, error) {
+	ciphertext, tag, err := m.EncryptStringWithMAC(plaintext, key, macKey)
+	if err != nil {
+		return "", err
+	}
+	return ciphertext + tag, nil
+}
+
+// DecryptStringMACSuffix verifies and decrypts a value from EncryptStringMACSuffix
+func (m *MySQLAES) DecryptStringMACSuffix(value, key, macKey string) (string, error) {
+	if len(value) < 2*MACSize {
+		return "", fmt.Errorf("value too short to contain a MAC")
+	}
+	split := len(value) - 2*MACSize
+	return m.DecryptStringWithMAC(value[:split], value[split:], key, macKey)
+}
+
+// MACSuffixSQL returns the MySQL expressions for the binary ciphertext and the hex tag of a
+// column holding EncryptStringMACSuffix values
+func MACSuffixSQL(column string) (ciphertextExpr, tagExpr string) {
+	ciphertextExpr = fmt.Sprintf("UNHEX(LEFT(%s, CHAR_LENGTH(%s) - %d))", column, column, 2*MACSize)
+	tagExpr = fmt.Sprintf("RIGHT(%s, %d)", column, 2*MACSize)
+	return ciphertextExpr, tagExpr
+}
+
+// MACVerifySQL returns a MySQL boolean expression that recomputes HMAC-SHA256 with SHA2 and
+// compares it to the hex tag. ciphertextExpr must evaluate to the binary ciphertext, e.g.
+// UNHEX(col). The expression embeds key material derived from macKey, so treat it as secret.
+func MACVerifySQL(ciphertextExpr, tagExpr string, macKey []byte) string {
+	ipad, opad := hmacPads(macKey)
+	return fmt.Sprintf("SHA2(CONCAT(UNHEX('%s'), UNHEX(SHA2(CONCAT(UNHEX('%s'), %s), 256))), 256) = LOWER(%s)",
+		hex.EncodeToString(opad), hex.EncodeToString(ipad), ciphertextExpr, tagExpr)
+}
+
+// hmacPads returns the inner and outer padded keys of HMAC-SHA256, so that
+// HMAC(k, m) = SHA256(opad || SHA256(ipad || m))
+func hmacPads(key []byte) ([]byte, []byte) {
+	blockSize := sha256.BlockSize
+	if len(key) > blockSize {
+		sum := sha256.Sum256(key)
+		key = sum[:]
+	}
+	ipad := make([]byte, blockSize)
+	opad := make([]byte, blockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	return ipad, opad
+}
@@ -0,0 +1,104 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMySQLAES_MAC(t *testing.T) {
+	aes := New()
+	key := "enc-key"
+	macKey := "mac-key"
+
+	ciphertext, tag, err := aes.EncryptStringWithMAC("sensitive data", key, macKey)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	// The ciphertext is unchanged MySQL-compatible output
+	plain, _ := aes.EncryptString("sensitive data", key)
+	if ciphertext != plain {
+		t.Errorf("Expected MySQL-compatible ciphertext %q, got %q", plain, ciphertext)
+	}
+
+	decrypted, err := aes.DecryptStringWithMAC(ciphertext, tag, key, macKey)
+	if err != nil || decrypted != "sensitive data" {
+		t.Fatalf("Decryption failed: %q, %v", decrypted, err)
+	}
+
+	other, _ := aes.EncryptString("other data", key)
+	testCases := []struct {
+		name       string
+		ciphertext string
+		tag        string
+		macKey     string
+	}{
+		{"swapped ciphertext", other, tag, macKey},
+		{"wrong MAC key", ciphertext, tag, "other-mac-key"},
+		{"truncated tag", ciphertext, tag[:10], macKey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := aes.DecryptStringWithMAC(tc.ciphertext, tc.tag, key, tc.macKey)
+			if !errors.Is(err, ErrAuthenticationFailed) {
+				t.Errorf("Expected ErrAuthenticationFailed, got %v", err)
+			}
+		})
+	}
+
+	if _, _, err := aes.EncryptStringWithMAC("x", key, ""); err == nil {
+		t.Error("Expected error for empty MAC key")
+	}
+}
+
+func TestMySQLAES_MACSuffix(t *testing.T) {
+	aes := New()
+	value, err := aes.EncryptStringMACSuffix("sensitive data", "enc-key", "mac-key")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	decrypted, err := aes.DecryptStringMACSuffix(value, "enc-key", "mac-key")
+	if err != nil || decrypted != "sensitive data" {
+		t.Fatalf("Decryption failed: %q, %v", decrypted, err)
+	}
+
+	tampered := "00" + value[2:]
+	if value[:2] == "00" {
+		tampered = "11" + value[2:]
+	}
+	if _, err := aes.DecryptStringMACSuffix(tampered, "enc-key", "mac-key"); !errors.Is(err, ErrAuthenticationFailed) {
+		t.Errorf("Expected ErrAuthenticationFailed, got %v", err)
+	}
+	if _, err := aes.DecryptStringMACSuffix("abcd", "enc-key", "mac-key"); err == nil {
+		t.Error("Expected error for short value")
+	}
+}
+
+func TestHMACPads(t *testing.T) {
+	message := []byte("ciphertext bytes")
+	for _, key := range [][]byte{[]byte("short"), bytes.Repeat([]byte("k"), 100)} {
+		ipad, opad := hmacPads(key)
+		inner := sha256.Sum256(append(ipad, message...))
+		outer := sha256.Sum256(append(opad, inner[:]...))
+		if !bytes.Equal(outer[:], ComputeMAC(message, key)) {
+			t.Errorf("Expected SQL HMAC construction to match HMAC-SHA256 for key length %d", len(key))
+		}
+	}
+}
+
+func TestMACSQL(t *testing.T) {
+	ciphertextExpr, tagExpr := MACSuffixSQL("`ssn`")
+	if ciphertextExpr != "UNHEX(LEFT(`ssn`, CHAR_LENGTH(`ssn`) - 64))" || tagExpr != "RIGHT(`ssn`, 64)" {
+		t.Errorf("Unexpected suffix expressions: %s, %s", ciphertextExpr, tagExpr)
+	}
+
+	expr := MACVerifySQL("UNHEX(`ssn`)", "`ssn_mac`", []byte("mac-key"))
+	if !strings.HasPrefix(expr, "SHA2(CONCAT(UNHEX('") || !strings.HasSuffix(expr, "UNHEX(`ssn`)), 256))), 256) = LOWER(`ssn_mac`)") {
+		t.Errorf("Unexpected verification expression: %s", expr)
+	}
+}
@@ -0,0 +1,223 @@
+// Package mariadb reads and writes the key files of MariaDB's file_key_management plugin.
+//
+// A key file holds one "id;hexkey" line per key, with 16, 24 or 32 byte keys and optional
+// # comments. It can be encrypted like `openssl enc -aes-256-cbc -md sha1 -k secret`: the
+// "Salted__" magic and an 8-byte salt, followed by the file encrypted with AES-256-CBC under
+// a key and IV derived from the secret with EVP_BytesToKey, or PBKDF2 when
+// file_key_management_use_pbkdf2 is set.
+package mariadb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ace3/mysql-aes"
+)
+
+const (
+	// SystemKeyID is the key MariaDB requires and uses unless a table chooses another
+	SystemKeyID = 1
+
+	// maxKeyFileSize is the largest key file the plugin reads
+	maxKeyFileSize = 1 << 20
+)
+
+// ErrWrongSecret is returned when an encrypted key file does not decrypt with the secret
+var ErrWrongSecret = errors.New("wrong key file secret")
+
+// Options selects the key derivation of an encrypted key file, mirroring the
+// file_key_management_digest and file_key_management_use_pbkdf2 settings. The zero value is
+// the plugin default: SHA-1 with EVP_BytesToKey.
+type Options struct {
+	// Digest is "sha1" (the default) or "sha256"
+	Digest string
+	// PBKDF2Iterations enables PBKDF2 with this many iterations instead of EVP_BytesToKey
+	PBKDF2Iterations int
+}
+
+// openssl returns the equivalent `openssl enc -aes-256-cbc` options
+func (o Options) openssl() mysql_aes.OpenSSLOptions {
+	opts := mysql_aes.OpenSSLOptions{Cipher: "aes-256-cbc", Digest: o.Digest}
+	if opts.Digest == "" {
+		opts.Digest = "sha1"
+	}
+	if o.PBKDF2Iterations > 0 {
+		opts.PBKDF2 = true
+		opts.Iterations = o.PBKDF2Iterations
+	}
+	return opts
+}
+
+// KeyFile holds the numbered keys of a file_key_management key file
+type KeyFile struct {
+	keys map[uint32][]byte
+}
+
+// NewKeyFile creates an empty key file
+func NewKeyFile() *KeyFile {
+	return &KeyFile{keys: make(map[uint32][]byte)}
+}
+
+// Load reads a key file from disk. Files starting with "Salted__" are decrypted with secret.
+func Load(path, secret string, opts Options) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if mysql_aes.IsOpenSSLSalted(data) {
+		return ParseEncrypted(data, secret, opts)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a plain key file. Like the plugin, it requires the system key with ID 1.
+func Parse(data []byte) (*KeyFile, error) {
+	if len(data) > maxKeyFileSize {
+		return nil, fmt.Errorf("key file is larger than %d bytes", maxKeyFileSize)
+	}
+	k := NewKeyFile()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		idText, keyHex, ok := strings.Cut(line, ";")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected id;hexkey", n)
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idText), 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("line %d: invalid key id %q", n, idText)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid hex key: %w", n, err)
+		}
+		if _, exists := k.keys[uint32(id)]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key id %d", n, id)
+		}
+		if err := k.Set(uint32(id), key); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if _, ok := k.keys[SystemKeyID]; !ok {
+		return nil, fmt.Errorf("system key id %d is missing", SystemKeyID)
+	}
+	return k, nil
+}
+
+// ParseEncrypted decrypts and decodes an OpenSSL "Salted__" key file
+func ParseEncrypted(data []byte, secret string, opts Options) (*KeyFile, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("key file is encrypted but no secret was given")
+	}
+	plain, err := mysql_aes.OpenSSLDecrypt(data, secret, opts.openssl())
+	if errors.Is(err, mysql_aes.ErrWrongPassphrase) {
+		return nil, ErrWrongSecret
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(plain)
+}
+
+// Key implements mysql_aes.KeySource with the decimal key ID, e.g. "1"
+func (k *KeyFile) Key(id string) ([]byte, error) {
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key id %q", id)
+	}
+	key, ok := k.keys[uint32(n)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", mysql_aes.ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// IDs returns the key IDs in ascending order
+func (k *KeyFile) IDs() []uint32 {
+	ids := make([]uint32, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Set adds or replaces a key
+func (k *KeyFile) Set(id uint32, key []byte) error {
+	if id == 0 {
+		return fmt.Errorf("key id must be positive")
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("key %d must be 16, 24 or 32 bytes, got %d", id, len(key))
+	}
+	k.keys[id] = append([]byte{}, key...)
+	return nil
+}
+
+// Rotate adds a random key of size bytes under the next free ID and returns the ID. Point
+// tables at it with ENCRYPTION_KEY_ID; the old keys stay available for existing data.
+func (k *KeyFile) Rotate(size int) (uint32, error) {
+	key := make([]byte, size)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return 0, fmt.Errorf("failed to generate key: %w", err)
+	}
+	id := uint32(SystemKeyID)
+	if ids := k.IDs(); len(ids) > 0 {
+		id = ids[len(ids)-1] + 1
+	}
+	if err := k.Set(id, key); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Marshal encodes the key file as sorted "id;hexkey" lines
+func (k *KeyFile) Marshal() []byte {
+	var b bytes.Buffer
+	for _, id := range k.IDs() {
+		fmt.Fprintf(&b, "%d;%s\n", id, strings.ToUpper(hex.EncodeToString(k.keys[id])))
+	}
+	return b.Bytes()
+}
+
+// MarshalEncrypted encodes and encrypts the key file in OpenSSL "Salted__" format
+func (k *KeyFile) MarshalEncrypted(secret string, opts Options) ([]byte, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret cannot be empty")
+	}
+	return mysql_aes.OpenSSLEncrypt(k.Marshal(), secret, opts.openssl())
+}
+
+// Save writes the key file with owner-only permissions, encrypted when secret is not empty
+func (k *KeyFile) Save(path, secret string, opts Options) error {
+	data := k.Marshal()
+	if secret != "" {
+		var err error
+		if data, err = k.MarshalEncrypted(secret, opts); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,152 @@
+package mariadb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ace3/mysql-aes"
+)
+
+const sampleKeyFile = "# keys\n" +
+	"1;770A8A65DA156D24EE2A093277530142\n" +
+	"2;4D92199549E0F2EF009B4160F3582E5528A11A45017F3EF8\n" +
+	"18;D2E2B00A6D8CE0D8C0C6A5C7F0F8F1C5D2E2B00A6D8CE0D8C0C6A5C7F0F8F1C5\n"
+
+// opensslSalted returns the output of `openssl enc -aes-256-cbc -k secret -S 0102030405060708`
+// for sampleKeyFile, with the Salted__ header OpenSSL 3 leaves out when -S is given
+func opensslSalted(t *testing.T, b64 string) []byte {
+	t.Helper()
+	body, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte("Salted__\x01\x02\x03\x04\x05\x06\x07\x08"), body...)
+}
+
+func TestParse(t *testing.T) {
+	k, err := Parse([]byte(sampleKeyFile))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if ids := k.IDs(); len(ids) != 3 || ids[0] != 1 || ids[2] != 18 {
+		t.Errorf("Unexpected key IDs %v", ids)
+	}
+	key, _ := k.Key("2")
+	if len(key) != 24 {
+		t.Errorf("Expected 24-byte key, got %d bytes", len(key))
+	}
+	if got := string(k.Marshal()); got != sampleKeyFile[len("# keys\n"):] {
+		t.Errorf("Expected %q, got %q", sampleKeyFile[len("# keys\n"):], got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"missing separator", "1 770A8A65DA156D24EE2A093277530142\n"},
+		{"zero id", "0;770A8A65DA156D24EE2A093277530142\n"},
+		{"bad hex", "1;770A8A65DA156D24EE2A09327753014Z\n"},
+		{"key size", "1;770A8A65\n"},
+		{"duplicate", "1;770A8A65DA156D24EE2A093277530142\n1;770A8A65DA156D24EE2A093277530142\n"},
+		{"no system key", "2;770A8A65DA156D24EE2A093277530142\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestParseEncrypted_OpenSSL(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		b64  string
+	}{
+		{
+			"sha1 EVP_BytesToKey",
+			Options{},
+			"Up8jYsvLfiWeAlS7bs66UaUmelGdpnfsWHcCrMdTdqruW2YMVf8nkK4+r8e5+JzuGN0pRFUZeEMsge1LPOatBBHqvaV8H9TBX9AEOoMaDW1bcpc88RCJ8DUtOo45Lot5cexS9Cf1D2wFE2t+ACFYdXbeBMPYh9zfk7awv6Pb1md4uDmAGJPQgJyyCiCTff8dNa6p45fANiLbyqg42sUM1qcyeJR7brhMzjXNAfKeQt4=",
+		},
+		{
+			"sha256 PBKDF2",
+			Options{Digest: "sha256", PBKDF2Iterations: 1000},
+			"C4XIM8unyFX8WfgT0Cf6462j6owehRh/c1mJixOWMjo2GYCM2OWDDqyhC+h3F6sR8GjzBqoNRphiuh+AF3lgLH7SiY9Ipy+1IujJUN3RcAlI0Zgs6BGWMh06ovLZ3VTh7xjYDEaJG6IIhccsdXFMxyNKU+YNgdjICxvsnPfvkvVpV4X2JsKWJWS5SBRrsZIcz0jV/t4kgTDjMjprPJNGYC5KousXWtDECGqyovouSS8=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := opensslSalted(t, tt.b64)
+			k, err := ParseEncrypted(data, "secret", tt.opts)
+			if err != nil {
+				t.Fatalf("ParseEncrypted failed: %v", err)
+			}
+			if len(k.IDs()) != 3 {
+				t.Errorf("Expected 3 keys, got %v", k.IDs())
+			}
+			if _, err := ParseEncrypted(data, "wrong", tt.opts); err == nil {
+				t.Error("Expected error for a wrong secret")
+			}
+		})
+	}
+}
+
+func TestKeyFile_SaveLoad(t *testing.T) {
+	k := NewKeyFile()
+	for i := 0; i < 2; i++ {
+		if _, err := k.Rotate(32); err != nil {
+			t.Fatalf("Rotate failed: %v", err)
+		}
+	}
+	if ids := k.IDs(); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Expected IDs [1 2], got %v", ids)
+	}
+
+	dir := t.TempDir()
+	opts := Options{Digest: "sha256", PBKDF2Iterations: 100}
+	for _, secret := range []string{"", "rotate me"} {
+		path := filepath.Join(dir, "keys"+secret)
+		if err := k.Save(path, secret, opts); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := Load(path, secret, opts)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if !bytes.Equal(loaded.Marshal(), k.Marshal()) {
+			t.Errorf("Loaded keys do not match (secret %q)", secret)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "keysrotate me"), "", opts); err == nil {
+		t.Error("Expected error for an encrypted file without secret")
+	}
+}
+
+func TestKeyFile_KeySource(t *testing.T) {
+	k, _ := Parse([]byte(sampleKeyFile))
+	aes := mysql_aes.New()
+	key, _ := k.Key("1")
+
+	encrypted, err := aes.EncryptStringWithKeySource("secret", k, "1")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	expected, _ := aes.EncryptString("secret", string(key))
+	if encrypted != expected {
+		t.Errorf("Expected %q, got %q", expected, encrypted)
+	}
+	if _, err := k.Key("3"); !errors.Is(err, mysql_aes.ErrKeyNotFound) {
+		t.Errorf("Expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := k.Key("one"); err == nil {
+		t.Error("Expected error for a non-numeric ID")
+	}
+}
@@ -0,0 +1,366 @@
+// Package mylogin reads and writes the .mylogin.cnf login path files managed by
+// mysql_config_editor.
+//
+// The file starts with 4 unused bytes and a 20-byte random key. Every line of the option
+// file text follows as a 4-byte little-endian length and the line encrypted with AES-128-ECB
+// under the key folded to 16 bytes, which is exactly the AES_ENCRYPT key handling of
+// mysql_aes.
+package mylogin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ace3/mysql-aes"
+)
+
+const (
+	// KeyLen is the length of the obfuscation key stored in the file header
+	KeyLen = 20
+	// headerUnused is the number of unused bytes before the key
+	headerUnused = 4
+	// maxLineLen bounds the encrypted line length accepted when reading
+	maxLineLen = 64 * 1024
+)
+
+// Option is a single option of a login path
+type Option struct {
+	Name  string
+	Value string
+	// NoValue marks a bare option without "= value"
+	NoValue bool
+}
+
+// Section is a login path such as [client] or [remote]
+type Section struct {
+	Name    string
+	Options []Option
+}
+
+// Get returns the value of an option
+func (s *Section) Get(name string) (string, bool) {
+	for _, o := range s.Options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets an option, replacing an existing value or appending a new option
+func (s *Section) Set(name, value string) {
+	for i, o := range s.Options {
+		if o.Name == name {
+			s.Options[i] = Option{Name: name, Value: value}
+			return
+		}
+	}
+	s.Options = append(s.Options, Option{Name: name, Value: value})
+}
+
+// Unset removes an option
+func (s *Section) Unset(name string) {
+	for i, o := range s.Options {
+		if o.Name == name {
+			s.Options = append(s.Options[:i], s.Options[i+1:]...)
+			return
+		}
+	}
+}
+
+// LoginPath holds the options mysql_config_editor manages for a login path
+type LoginPath struct {
+	User     string
+	Password string
+	Host     string
+	Port     int
+	Socket   string
+}
+
+// File is a decoded .mylogin.cnf file
+type File struct {
+	key      [KeyLen]byte
+	Sections []*Section
+}
+
+// New creates an empty login path file with a fresh random key
+func New() (*File, error) {
+	f := &File{}
+	if _, err := io.ReadFull(rand.Reader, f.key[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return f, nil
+}
+
+// Load reads a login path file from disk
+func Load(path string) (*File, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return Read(fh)
+}
+
+// Read decodes a login path file
+func Read(r io.Reader) (*File, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, headerUnused+KeyLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	f := &File{}
+	copy(f.key[:], header[headerUnused:])
+
+	aes := mysql_aes.New()
+	var text bytes.Buffer
+	for {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(br, lenBuf[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read line length: %w", err)
+		}
+		n := binary.LittleEndian.Uint32(lenBuf[:])
+		if n == 0 || n > maxLineLen || n%mysql_aes.BlockSize != 0 {
+			return nil, fmt.Errorf("invalid encrypted line length %d", n)
+		}
+		cipher := make([]byte, n)
+		if _, err := io.ReadFull(br, cipher); err != nil {
+			return nil, fmt.Errorf("failed to read line: %w", err)
+		}
+		line, err := aes.Decrypt(cipher, f.key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt line: %w", err)
+		}
+		text.Write(line)
+	}
+
+	sections, err := parseOptions(text.String())
+	if err != nil {
+		return nil, err
+	}
+	f.Sections = sections
+	return f, nil
+}
+
+// Save writes the file to disk with the 0600 permissions the MySQL clients require
+func (f *File) Save(path string) error {
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file, so enforce it
+	return os.Chmod(path, 0600)
+}
+
+// Write encodes the file
+func (f *File) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(make([]byte, headerUnused))
+	bw.Write(f.key[:])
+
+	aes := mysql_aes.New()
+	for _, line := range f.lines() {
+		cipher, err := aes.Encrypt([]byte(line), f.key[:])
+		if err != nil {
+			return fmt.Errorf("failed to encrypt line: %w", err)
+		}
+		var lenBuf [4]byte
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(cipher)))
+		bw.Write(lenBuf[:])
+		bw.Write(cipher)
+	}
+	return bw.Flush()
+}
+
+// String returns the decrypted option file text
+func (f *File) String() string {
+	return strings.Join(f.lines(), "")
+}
+
+// lines renders the option file text one newline-terminated line at a time
+func (f *File) lines() []string {
+	var lines []string
+	for _, s := range f.Sections {
+		lines = append(lines, "["+s.Name+"]\n")
+		for _, o := range s.Options {
+			if o.NoValue {
+				lines = append(lines, o.Name+"\n")
+			} else {
+				lines = append(lines, o.Name+" = "+formatValue(o.Name, o.Value)+"\n")
+			}
+		}
+	}
+	return lines
+}
+
+// Section returns a login path by name, or nil if it does not exist
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Names returns the login path names in file order
+func (f *File) Names() []string {
+	names := make([]string, len(f.Sections))
+	for i, s := range f.Sections {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Get returns the standard options of a login path
+func (f *File) Get(name string) (LoginPath, bool) {
+	s := f.Section(name)
+	if s == nil {
+		return LoginPath{}, false
+	}
+	var lp LoginPath
+	lp.User, _ = s.Get("user")
+	lp.Password, _ = s.Get("password")
+	lp.Host, _ = s.Get("host")
+	lp.Socket, _ = s.Get("socket")
+	if port, ok := s.Get("port"); ok {
+		lp.Port, _ = strconv.Atoi(port)
+	}
+	return lp, true
+}
+
+// Set creates or updates a login path like mysql_config_editor set: empty fields and a zero
+// port are left out, and other options already in the section are kept
+func (f *File) Set(name string, lp LoginPath) {
+	s := f.Section(name)
+	if s == nil {
+		s = &Section{Name: name}
+		f.Sections = append(f.Sections, s)
+	}
+	set := func(option, value string) {
+		if value == "" {
+			s.Unset(option)
+		} else {
+			s.Set(option, value)
+		}
+	}
+	set("user", lp.User)
+	set("password", lp.Password)
+	set("host", lp.Host)
+	if lp.Port != 0 {
+		s.Set("port", strconv.Itoa(lp.Port))
+	} else {
+		s.Unset("port")
+	}
+	set("socket", lp.Socket)
+}
+
+// Remove deletes a login path and reports whether it existed
+func (f *File) Remove(name string) bool {
+	for i, s := range f.Sections {
+		if s.Name == name {
+			f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// formatValue quotes values the way mysql_config_editor writes them; the port stays bare
+func formatValue(name, value string) string {
+	if name == "port" {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// parseOptions parses MySQL option file text into sections
+func parseOptions(text string) ([]*Section, error) {
+	var sections []*Section
+	var current *Section
+	for i, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: invalid group %q", i+1, line)
+			}
+			current = &Section{Name: strings.TrimSpace(line[1:end])}
+			sections = append(sections, current)
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: option outside of a group", i+1)
+		}
+
+		name, value, hasValue := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !hasValue {
+			current.Options = append(current.Options, Option{Name: name, NoValue: true})
+			continue
+		}
+		parsed, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		current.Options = append(current.Options, Option{Name: name, Value: parsed})
+	}
+	return sections, nil
+}
+
+// parseValue unquotes an option value and expands MySQL option file escapes
+func parseValue(value string) (string, error) {
+	quote := byte(0)
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		quote = value[0]
+		if len(value) < 2 || value[len(value)-1] != quote {
+			return "", fmt.Errorf("unterminated quoted value")
+		}
+		value = value[1 : len(value)-1]
+	} else if hash := strings.Index(value, " #"); hash >= 0 {
+		// Unquoted values end at a comment
+		value = strings.TrimSpace(value[:hash])
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'b':
+			b.WriteByte('\b')
+		case 's':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String(), nil
+}
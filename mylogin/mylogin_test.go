@@ -0,0 +1,206 @@
+package mylogin
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// decryptECB decrypts one line independently of mysql_aes, folding the 20-byte key by hand
+func decryptECB(t *testing.T, key, cipher []byte) string {
+	t.Helper()
+	folded := make([]byte, 16)
+	for i, b := range key {
+		folded[i%16] ^= b
+	}
+	block, err := aes.NewCipher(folded)
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+	out := make([]byte, len(cipher))
+	for i := 0; i < len(cipher); i += 16 {
+		block.Decrypt(out[i:i+16], cipher[i:i+16])
+	}
+	pad := int(out[len(out)-1])
+	return string(out[:len(out)-pad])
+}
+
+func TestFile_Layout(t *testing.T) {
+	f, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	f.Set("client", LoginPath{User: "root", Password: `p"a\ss#1`, Host: "localhost"})
+	f.Set("remote", LoginPath{User: "app", Host: "db.example.com", Port: 3307})
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data := buf.Bytes()
+
+	if !bytes.Equal(data[:4], []byte{0, 0, 0, 0}) {
+		t.Errorf("Expected 4 unused zero bytes, got %x", data[:4])
+	}
+	key := data[4 : 4+KeyLen]
+
+	var lines []string
+	for rest := data[4+KeyLen:]; len(rest) > 0; {
+		n := binary.LittleEndian.Uint32(rest)
+		lines = append(lines, decryptECB(t, key, rest[4:4+n]))
+		rest = rest[4+n:]
+	}
+
+	expected := []string{
+		"[client]\n",
+		"user = \"root\"\n",
+		"password = \"p\\\"a\\\\ss#1\"\n",
+		"host = \"localhost\"\n",
+		"[remote]\n",
+		"user = \"app\"\n",
+		"host = \"db.example.com\"\n",
+		"port = 3307\n",
+	}
+	if strings.Join(lines, "") != strings.Join(expected, "") {
+		t.Errorf("Expected lines %q, got %q", expected, lines)
+	}
+}
+
+// TestLoad_MySQL57 reads a file written by mysql_config_editor: mylogin-bad-cipher.cnf from
+// MySQL 5.7's mysql-test/std_data, with the nine bytes the test overwrote with '1' restored
+// (each has a single value that gives valid padding) and its trailing newline removed
+func TestLoad_MySQL57(t *testing.T) {
+	f, err := Load(filepath.Join("testdata", "mylogin.cnf"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if names := strings.Join(f.Names(), ","); names != "client,yashlogin" {
+		t.Errorf("Expected %q, got %q", "client,yashlogin", names)
+	}
+	for _, name := range []string{"client", "yashlogin"} {
+		lp, ok := f.Get(name)
+		if !ok || lp.User != "yash" || lp.Password != "1234" {
+			t.Errorf("Unexpected login path %q: %+v", name, lp)
+		}
+	}
+
+	// The file's own key and line padding decrypt every line independently of Read
+	data, err := os.ReadFile(filepath.Join("testdata", "mylogin.cnf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decryptECB(t, data[4:4+KeyLen], data[28:44]); got != "[client]\n" {
+		t.Errorf("Expected %q, got %q", "[client]\n", got)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	again, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if lp, _ := again.Get("yashlogin"); lp.User != "yash" || lp.Password != "1234" {
+		t.Errorf("Unexpected login path after rewrite: %+v", lp)
+	}
+}
+
+func TestFile_RoundTrip(t *testing.T) {
+	f, err := New()
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	want := LoginPath{User: "root", Password: `p"a\ss #1`, Host: "localhost", Port: 3306, Socket: "/tmp/mysql.sock"}
+	f.Set("client", want)
+
+	path := filepath.Join(t.TempDir(), ".mylogin.cnf")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got, ok := loaded.Get("client")
+	if !ok {
+		t.Fatal("Expected login path client")
+	}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	// Editing keeps the key and removes cleared options
+	loaded.Set("client", LoginPath{User: "admin", Host: "localhost"})
+	if _, ok := loaded.Section("client").Get("password"); ok {
+		t.Error("Expected password to be removed")
+	}
+	if !loaded.Remove("client") || loaded.Remove("client") {
+		t.Error("Expected Remove to report existence")
+	}
+	if len(loaded.Names()) != 0 {
+		t.Errorf("Expected no login paths, got %v", loaded.Names())
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	text := "# comment\n[client]\nuser=bob\npassword = 'single'\nhost = h # trailing\nskip-ssl\nsocket = \"a\\sb\"\n"
+	sections, err := parseOptions(text)
+	if err != nil {
+		t.Fatalf("parseOptions failed: %v", err)
+	}
+	if len(sections) != 1 {
+		t.Fatalf("Expected 1 section, got %d", len(sections))
+	}
+	s := sections[0]
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"user", "bob"},
+		{"password", "single"},
+		{"host", "h"},
+		{"socket", "a b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := s.Get(tt.name)
+			if !ok || got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+	if o := s.Options[3]; o.Name != "skip-ssl" || !o.NoValue {
+		t.Errorf("Expected bare option skip-ssl, got %+v", o)
+	}
+
+	if _, err := parseOptions("user = bob\n"); err == nil {
+		t.Error("Expected error for option outside of a group")
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	if _, err := Read(bytes.NewReader(make([]byte, 10))); err == nil {
+		t.Error("Expected error for truncated header")
+	}
+
+	data := make([]byte, 4+KeyLen)
+	data = binary.LittleEndian.AppendUint32(data, 7)
+	data = append(data, make([]byte, 7)...)
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Expected error for invalid line length")
+	}
+}
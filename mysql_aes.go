@@ -7,9 +7,12 @@ package mysql_aes
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -19,31 +22,31 @@ const (
 	BlockSize = aes.BlockSize
 )
 
+// errInvalidPadding is returned when decrypted data does not end in valid padding, which
+// usually means the key was wrong
+var errInvalidPadding = errors.New("invalid padding")
+
 // MySQLAES provides MySQL-compatible AES encryption and decryption operations
-type MySQLAES struct{}
+type MySQLAES struct {
+	profile Profile
+	padding Padding
+}
 
 // New creates a new MySQLAES instance
 func New() *MySQLAES {
 	return &MySQLAES{}
 }
 
-// aesKey processes the key to match MySQL's key handling behavior.
-// MySQL wraps keys longer than 16 bytes back into the 16-byte key array using XOR.
-func (m *MySQLAES) aesKey(key []byte) []byte {
-	const keyLen = AESKeyLen / 8 // 16 bytes for 128-bit key
-
-	if len(key) == keyLen {
+// aesKey processes the key to match MySQL's key handling behavior: the key is XORed into a
+// size-byte buffer, so longer keys wrap around and shorter ones are zero-padded. Encrypt and
+// Decrypt use 16 bytes; the block encryption modes use their key size.
+func aesKey(key []byte, size int) []byte {
+	if len(key) == size {
 		return key
 	}
-
-	k := make([]byte, keyLen)
-	copy(k, key)
-	
-	// XOR wrap-around for keys longer than 16 bytes
-	for i := keyLen; i < len(key); {
-		for j := 0; j < keyLen && i < len(key); j, i = j+1, i+1 {
-			k[j] ^= key[i]
-		}
+	k := make([]byte, size)
+	for i, c := range key {
+		k[i%size] ^= c
 	}
 	return k
 }
@@ -53,18 +56,26 @@ func (m *MySQLAES) Encrypt(plaintext, key []byte) ([]byte, error) {
 	if len(plaintext) == 0 {
 		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
+	return m.encrypt(plaintext, key)
+}
+
+// encrypt performs AES_ENCRYPT without rejecting empty plaintext, which MySQL encrypts
+// to a single block of padding
+func (m *MySQLAES) encrypt(plaintext, key []byte) ([]byte, error) {
 	if len(key) == 0 {
 		return nil, fmt.Errorf("key cannot be empty")
 	}
 
-	processedKey := m.aesKey(key)
+	processedKey := aesKey(key, AESKeyLen/8)
 	block, err := aes.NewCipher(processedKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
-	// Apply PKCS7 padding
-	paddedText := m.pkcs7Pad(plaintext, BlockSize)
+	paddedText, err := m.Padding().Pad(plaintext, BlockSize)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Encrypt using ECB mode
 	ciphertext := make([]byte, len(paddedText))
@@ -77,6 +88,21 @@ func (m *MySQLAES) Encrypt(plaintext, key []byte) ([]byte, error) {
 
 // Decrypt decrypts ciphertext using AES-ECB mode, compatible with MySQL's AES_DECRYPT function
 func (m *MySQLAES) Decrypt(ciphertext, key []byte) ([]byte, error) {
+	plaintext, err := m.decryptBlocks(ciphertext, key)
+	if err != nil {
+		return nil, err
+	}
+
+	unpaddedText, err := m.Padding().Unpad(plaintext, BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove padding: %w", err)
+	}
+
+	return unpaddedText, nil
+}
+
+// decryptBlocks decrypts ciphertext using AES-ECB mode without removing the padding
+func (m *MySQLAES) decryptBlocks(ciphertext, key []byte) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, fmt.Errorf("ciphertext cannot be empty")
 	}
@@ -87,7 +113,7 @@ func (m *MySQLAES) Decrypt(ciphertext, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("ciphertext length must be multiple of block size")
 	}
 
-	processedKey := m.aesKey(key)
+	processedKey := aesKey(key, AESKeyLen/8)
 	block, err := aes.NewCipher(processedKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cipher: %w", err)
@@ -98,14 +124,7 @@ func (m *MySQLAES) Decrypt(ciphertext, key []byte) ([]byte, error) {
 	for i := 0; i < len(ciphertext); i += BlockSize {
 		block.Decrypt(plaintext[i:i+BlockSize], ciphertext[i:i+BlockSize])
 	}
-
-	// Remove PKCS7 padding
-	unpaddedText, err := m.pkcs7Unpad(plaintext)
-	if err != nil {
-		return nil, fmt.Errorf("failed to remove padding: %w", err)
-	}
-
-	return unpaddedText, nil
+	return plaintext, nil
 }
 
 // EncryptString encrypts a string and returns the result as a hex string
@@ -119,6 +138,9 @@ func (m *MySQLAES) EncryptString(plaintext, key string) (string, error) {
 
 // DecryptString decrypts a hex string and returns the result as a string
 func (m *MySQLAES) DecryptString(ciphertextHex, key string) (string, error) {
+	if IsGCM(ciphertextHex) {
+		return "", fmt.Errorf("value is AES-GCM ciphertext, use DecryptGCMString")
+	}
 	ciphertext, err := hex.DecodeString(ciphertextHex)
 	if err != nil {
 		return "", fmt.Errorf("invalid hex string: %w", err)
@@ -132,35 +154,47 @@ func (m *MySQLAES) DecryptString(ciphertextHex, key string) (string, error) {
 	return string(decrypted), nil
 }
 
-// pkcs7Pad applies PKCS7 padding to the data
-func (m *MySQLAES) pkcs7Pad(data []byte, blockSize int) []byte {
-	padding := blockSize - len(data)%blockSize
-	padText := make([]byte, padding)
-	for i := range padText {
-		padText[i] = byte(padding)
+// EncryptBase64 encrypts a string and returns the result as base64, readable with FROM_BASE64
+func (m *MySQLAES) EncryptBase64(plaintext, key string) (string, error) {
+	encrypted, err := m.Encrypt([]byte(plaintext), []byte(key))
+	if err != nil {
+		return "", err
 	}
-	return append(data, padText...)
+	return base64.StdEncoding.EncodeToString(encrypted), nil
 }
 
-// pkcs7Unpad removes PKCS7 padding from the data
-func (m *MySQLAES) pkcs7Unpad(data []byte) ([]byte, error) {
-	if len(data) == 0 {
-		return nil, fmt.Errorf("data is empty")
+// DecryptBase64 decrypts a base64 string, including TO_BASE64 output with its line breaks
+func (m *MySQLAES) DecryptBase64(ciphertextBase64, key string) (string, error) {
+	if IsGCM(ciphertextBase64) {
+		return "", fmt.Errorf("value is AES-GCM ciphertext, use DecryptGCMString")
 	}
-	
-	padding := int(data[len(data)-1])
-	if padding == 0 || padding > len(data) {
-		return nil, fmt.Errorf("invalid padding")
+	ciphertext, err := decodeBase64(ciphertextBase64)
+	if err != nil {
+		return "", err
 	}
-	
-	// Verify padding
-	for i := len(data) - padding; i < len(data); i++ {
-		if data[i] != byte(padding) {
-			return nil, fmt.Errorf("invalid padding")
+
+	decrypted, err := m.Decrypt(ciphertext, []byte(key))
+	if err != nil {
+		return "", err
+	}
+
+	return string(decrypted), nil
+}
+
+// decodeBase64 decodes standard base64, ignoring the white space MySQL's TO_BASE64 inserts
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
 		}
+		return r
+	}, s)
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 string: %w", err)
 	}
-	
-	return data[:len(data)-padding], nil
+	return data, nil
 }
 
 // UserKeyDeriver provides functionality for deriving user-specific encryption keys
@@ -179,23 +213,25 @@ func NewUserKeyDeriver(baseKey, masterSalt string) *UserKeyDeriver {
 
 // DeriveUserKey derives a user-specific encryption key using the formula: baseKey + userID + ":" + masterSalt
 func (ukd *UserKeyDeriver) DeriveUserKey(userID interface{}) string {
-	var userIDStr string
-	switch v := userID.(type) {
+	return ukd.baseKey + formatID(userID) + ":" + ukd.masterSalt
+}
+
+// formatID converts a user or row ID to the string MySQL's CONCAT would produce for it
+func formatID(id interface{}) string {
+	switch v := id.(type) {
 	case uint:
-		userIDStr = strconv.FormatUint(uint64(v), 10)
+		return strconv.FormatUint(uint64(v), 10)
 	case uint64:
-		userIDStr = strconv.FormatUint(v, 10)
+		return strconv.FormatUint(v, 10)
 	case int:
-		userIDStr = strconv.Itoa(v)
+		return strconv.Itoa(v)
 	case int64:
-		userIDStr = strconv.FormatInt(v, 10)
+		return strconv.FormatInt(v, 10)
 	case string:
-		userIDStr = v
+		return v
 	default:
-		userIDStr = fmt.Sprintf("%v", userID)
+		return fmt.Sprintf("%v", id)
 	}
-	
-	return ukd.baseKey + userIDStr + ":" + ukd.masterSalt
 }
 
 // EncryptForUser encrypts data for a specific user using a derived key
@@ -110,6 +110,33 @@ func TestMySQLAES_ErrorHandling(t *testing.T) {
 	}
 }
 
+func TestMySQLAES_Base64(t *testing.T) {
+	aes := New()
+	plaintext := "This is a longer text that spans multiple blocks and wraps in TO_BASE64 output"
+	key := "base64key"
+
+	encrypted, err := aes.EncryptBase64(plaintext, key)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	// MySQL's TO_BASE64 breaks lines every 76 characters
+	wrapped := encrypted[:76] + "\n" + encrypted[76:]
+	for _, value := range []string{encrypted, wrapped} {
+		decrypted, err := aes.DecryptBase64(value, key)
+		if err != nil {
+			t.Fatalf("Decryption failed: %v", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Expected %q, got %q", plaintext, decrypted)
+		}
+	}
+
+	if _, err := aes.DecryptBase64("not base64!", key); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+}
+
 func TestUserKeyDeriver(t *testing.T) {
 	baseKey := "S4ty7H3mhy9sdaP54TRVne6ABDSafKqZ"
 	masterSalt := "testsalt"
@@ -204,8 +231,6 @@ func TestMySQLAES_Compatibility(t *testing.T) {
 }
 
 func TestPKCS7Padding(t *testing.T) {
-	aes := New()
-	
 	testCases := []struct {
 		name string
 		data []byte
@@ -219,7 +244,10 @@ func TestPKCS7Padding(t *testing.T) {
 	
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			padded := aes.pkcs7Pad(tc.data, 16)
+			padded, err := PKCS7Padding.Pad(tc.data, 16)
+			if err != nil {
+				t.Fatalf("Padding failed: %v", err)
+			}
 			
 			// Check padding length
 			if len(padded)%16 != 0 {
@@ -227,7 +255,7 @@ func TestPKCS7Padding(t *testing.T) {
 			}
 			
 			// Check if we can unpad
-			unpadded, err := aes.pkcs7Unpad(padded)
+			unpadded, err := PKCS7Padding.Unpad(padded, 16)
 			if err != nil {
 				t.Fatalf("Unpadding failed: %v", err)
 			}
@@ -0,0 +1,271 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+const (
+	// OpenSSLSaltedMagic starts the output of passphrase-based `openssl enc`
+	OpenSSLSaltedMagic = "Salted__"
+	// OpenSSLSaltSize is the size of the salt following the magic
+	OpenSSLSaltSize = 8
+	// DefaultOpenSSLCipher is the cipher used when OpenSSLOptions.Cipher is empty
+	DefaultOpenSSLCipher = "aes-256-cbc"
+	// DefaultPBKDF2Iterations is the -iter default of `openssl enc -pbkdf2`
+	DefaultPBKDF2Iterations = 10000
+)
+
+// ErrWrongPassphrase is returned when a "Salted__" container does not decrypt with the
+// passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// OpenSSLOptions mirrors the `openssl enc` flags that select the cipher and key derivation
+type OpenSSLOptions struct {
+	// Cipher is an AES cipher name such as aes-128-ecb or aes-256-cbc (the default)
+	Cipher string
+	// Digest is the -md digest: md5, sha1, sha256 (the default since OpenSSL 1.1), sha384
+	// or sha512
+	Digest string
+	// PBKDF2 selects -pbkdf2 instead of EVP_BytesToKey
+	PBKDF2 bool
+	// Iterations is the -iter count for PBKDF2 (default 10000), or the EVP_BytesToKey count
+	// (default 1, the only count openssl enc uses)
+	Iterations int
+}
+
+// cipher parses the cipher name into its key size and cryptBlocks mode
+func (o OpenSSLOptions) cipher() (int, string, error) {
+	name := o.Cipher
+	if name == "" {
+		name = DefaultOpenSSLCipher
+	}
+	var bits int
+	var mode string
+	if _, err := fmt.Sscanf(strings.ToLower(name), "aes-%d-%s", &bits, &mode); err != nil || (bits != 128 && bits != 192 && bits != 256) {
+		return 0, "", fmt.Errorf("unsupported cipher %q", name)
+	}
+	switch mode {
+	case "cfb":
+		mode = "cfb128"
+	case "ecb", "cbc", "cfb1", "cfb8", "ofb", "ctr":
+	default:
+		return 0, "", fmt.Errorf("unsupported cipher %q", name)
+	}
+	return bits / 8, mode, nil
+}
+
+// hash returns the digest constructor selected by the options
+func (o OpenSSLOptions) hash() (func() hash.Hash, error) {
+	switch strings.ToLower(o.Digest) {
+	case "md5":
+		return md5.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha384":
+		return sha512.New384, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported digest %q", o.Digest)
+	}
+}
+
+// DeriveKey derives the key and IV `openssl enc` uses for the passphrase and salt. The IV
+// is nil for ECB.
+func (o OpenSSLOptions) DeriveKey(passphrase string, salt []byte) ([]byte, []byte, error) {
+	keySize, mode, err := o.cipher()
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := o.hash()
+	if err != nil {
+		return nil, nil, err
+	}
+	ivSize := BlockSize
+	if mode == "ecb" {
+		ivSize = 0
+	}
+
+	iterations := o.Iterations
+	var material []byte
+	if o.PBKDF2 {
+		if iterations <= 0 {
+			iterations = DefaultPBKDF2Iterations
+		}
+		material = PBKDF2(h, []byte(passphrase), salt, iterations, keySize+ivSize)
+	} else {
+		if iterations <= 0 {
+			iterations = 1
+		}
+		material = EVPBytesToKey(h, []byte(passphrase), salt, iterations, keySize+ivSize)
+	}
+	if ivSize == 0 {
+		return material, nil, nil
+	}
+	return material[:keySize], material[keySize:], nil
+}
+
+// EVPBytesToKey implements OpenSSL's EVP_BytesToKey: D_i = H^count(D_{i-1} || password ||
+// salt), concatenated until size bytes are produced. A nil salt is left out.
+func EVPBytesToKey(h func() hash.Hash, password, salt []byte, count, size int) []byte {
+	var out, prev []byte
+	for len(out) < size {
+		d := h()
+		d.Write(prev)
+		d.Write(password)
+		d.Write(salt)
+		prev = d.Sum(nil)
+		for i := 1; i < count; i++ {
+			d.Reset()
+			d.Write(prev)
+			prev = d.Sum(prev[:0])
+		}
+		out = append(out, prev...)
+	}
+	return out[:size]
+}
+
+// PBKDF2 implements PBKDF2 with HMAC over h (RFC 8018)
+func PBKDF2(h func() hash.Hash, password, salt []byte, iterations, size int) []byte {
+	prf := hmac.New(h, password)
+	var out []byte
+	for block := uint32(1); len(out) < size; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:size]
+}
+
+// IsOpenSSLSalted reports whether data starts with the "Salted__" magic
+func IsOpenSSLSalted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(OpenSSLSaltedMagic))
+}
+
+// OpenSSLEncrypt encrypts like `openssl enc -<cipher> -md <digest> -k passphrase` with a
+// random salt, returning the "Salted__" container
+func OpenSSLEncrypt(plaintext []byte, passphrase string, opts OpenSSLOptions) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	salt := make([]byte, OpenSSLSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return openSSLEncrypt(plaintext, passphrase, salt, opts)
+}
+
+// openSSLEncrypt encrypts with a given salt
+func openSSLEncrypt(plaintext []byte, passphrase string, salt []byte, opts OpenSSLOptions) ([]byte, error) {
+	_, mode, err := opts.cipher()
+	if err != nil {
+		return nil, err
+	}
+	key, iv, err := opts.DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	if mode == "ecb" || mode == "cbc" {
+		if plaintext, err = PKCS7Padding.Pad(plaintext, BlockSize); err != nil {
+			return nil, err
+		}
+	}
+	out := append([]byte(OpenSSLSaltedMagic), salt...)
+	return append(out, cryptBlocks(block, mode, iv, plaintext, true)...), nil
+}
+
+// OpenSSLDecrypt decrypts a "Salted__" container like `openssl enc -d`
+func OpenSSLDecrypt(data []byte, passphrase string, opts OpenSSLOptions) ([]byte, error) {
+	if !IsOpenSSLSalted(data) || len(data) < len(OpenSSLSaltedMagic)+OpenSSLSaltSize {
+		return nil, fmt.Errorf("not an OpenSSL salted file")
+	}
+	_, mode, err := opts.cipher()
+	if err != nil {
+		return nil, err
+	}
+	salt := data[len(OpenSSLSaltedMagic) : len(OpenSSLSaltedMagic)+OpenSSLSaltSize]
+	encrypted := data[len(OpenSSLSaltedMagic)+OpenSSLSaltSize:]
+	padded := mode == "ecb" || mode == "cbc"
+	if padded && (len(encrypted) == 0 || len(encrypted)%BlockSize != 0) {
+		return nil, fmt.Errorf("invalid encrypted data length")
+	}
+
+	key, iv, err := opts.DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	plain := cryptBlocks(block, mode, iv, encrypted, false)
+	if !padded {
+		return plain, nil
+	}
+	unpadded, err := PKCS7Padding.Unpad(plain, BlockSize)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return unpadded, nil
+}
+
+// OpenSSLCommand returns the `openssl enc` command that reproduces EncryptMode (or
+// DecryptMode when decrypt is set) for the key, IV and block_encryption_mode, with the
+// folded key passed as -K. An empty mode is aes-128-ecb, the mode of Encrypt.
+func (m *MySQLAES) OpenSSLCommand(key, iv []byte, mode string, decrypt bool) (string, error) {
+	b, err := m.parseBlockMode(mode)
+	if err != nil {
+		return "", err
+	}
+	if len(key) == 0 {
+		return "", fmt.Errorf("key cannot be empty")
+	}
+	iv, err = m.checkIV(b, iv)
+	if err != nil {
+		return "", err
+	}
+
+	name := b.mode
+	if name == "cfb128" {
+		name = "cfb"
+	}
+	cmd := fmt.Sprintf("openssl enc -aes-%d-%s -K %s", b.keySize*8, name, hex.EncodeToString(aesKey(key, b.keySize)))
+	if iv != nil {
+		cmd += " -iv " + hex.EncodeToString(iv)
+	}
+	if decrypt {
+		cmd += " -d"
+	}
+	return cmd, nil
+}
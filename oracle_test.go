@@ -0,0 +1,91 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestOracleEncrypt_Vectors(t *testing.T) {
+	// Expected values from openssl enc with the same key, IV and padding. None was produced
+	// by DBMS_CRYPTO, so the CFB feedback width, PAD_ZERO and the 3DES_2KEY key layout are
+	// the documented behavior rather than checked Oracle output.
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	iv16, _ := hex.DecodeString("0f0e0d0c0b0a09080706050403020100")
+	iv8 := iv16[:8]
+	src := []byte("Oracle RAW data!x")
+
+	testCases := []struct {
+		name     string
+		typ      int
+		iv       []byte
+		expected string
+	}{
+		{"aes128 cbc pkcs5", OracleEncryptAES128 + OracleChainCBC + OraclePadPKCS5, iv16, "740cf2a6228b8939b6f48c88bf0f479c9ff67153ae73adf45488ebe5d81c66aa"},
+		{"aes256 ecb zero", OracleEncryptAES256 + OracleChainECB + OraclePadZero, nil, "46940baf99ec5b8babc13027a64dd5aefeade7c181041268d2ee161cc550edb0"},
+		{"aes192 cfb none", OracleEncryptAES192 + OracleChainCFB + OraclePadNone, iv16, "65d54043a33da5a953c18f143bffd6b97d"},
+		{"aes128 ofb none", OracleEncryptAES128 + OracleChainOFB + OraclePadNone, iv16, "6fdb98f1d8297bba4548dcb80ddaf84b9c"},
+		{"3des 2key ecb pkcs5", OracleEncrypt3DES2Key + OracleChainECB + OraclePadPKCS5, nil, "dbbe653fbff4497302c0fdf22e812529eb9d71d699be451f"},
+		{"3des cbc pkcs5", OracleEncrypt3DES + OracleChainCBC + OraclePadPKCS5, iv8, "0ea05d36a1d1f5464a0d4d6b38e1b972252895c69bb1a9bb"},
+		{"des cbc pkcs5", OracleEncryptDES + OracleChainCBC + OraclePadPKCS5, iv8, "d78e81b0635acb87097af28fd3fcb78ff16346ae73509c3b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted, err := OracleEncrypt(src, tc.typ, key, tc.iv)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			if got := hex.EncodeToString(encrypted); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+			decrypted, err := OracleDecrypt(encrypted, tc.typ, key, tc.iv)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, src) {
+				t.Errorf("Expected %q, got %q", src, decrypted)
+			}
+		})
+	}
+}
+
+func TestOracleEncrypt_ZeroPadAligned(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 16)
+	src := []byte("0123456789abcdef")
+	encrypted, err := OracleEncrypt(src, OracleEncryptAES128+OracleChainCBC+OraclePadZero, key, nil)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if len(encrypted) != len(src) {
+		t.Errorf("Expected no padding block, got %d bytes", len(encrypted))
+	}
+}
+
+func TestOracleEncrypt_Errors(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	aesCBC := OracleEncryptAES128 + OracleChainCBC + OraclePadPKCS5
+
+	testCases := []struct {
+		name string
+		src  []byte
+		typ  int
+		key  []byte
+		iv   []byte
+	}{
+		{"unsupported cipher", []byte("x"), 129 + OracleChainCBC + OraclePadPKCS5, key, nil},
+		{"missing chaining", []byte("x"), OracleEncryptAES128 + OraclePadPKCS5, key, nil},
+		{"missing padding", []byte("x"), OracleEncryptAES128 + OracleChainCBC, key, nil},
+		{"short key", []byte("x"), OracleEncryptAES256 + OracleChainCBC + OraclePadPKCS5, key[:16], nil},
+		{"wrong IV length", []byte("x"), aesCBC, key, make([]byte, 8)},
+		{"unaligned without padding", []byte("x"), OracleEncryptAES128 + OracleChainCBC + OraclePadNone, key, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := OracleEncrypt(tc.src, tc.typ, tc.key, tc.iv); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
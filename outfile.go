@@ -0,0 +1,405 @@
+package mysql_aes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutfileFormat describes the FIELDS and LINES options of SELECT ... INTO OUTFILE and
+// LOAD DATA INFILE. A zero escape or enclosure byte means the option is empty.
+type OutfileFormat struct {
+	FieldsTerminatedBy string
+	FieldsEnclosedBy   byte
+	// OptionallyEnclosed only encloses values that were enclosed on input or that were transformed
+	OptionallyEnclosed bool
+	FieldsEscapedBy    byte
+	LinesStartingBy    string
+	LinesTerminatedBy  string
+	// Header indicates that the first line holds column names (load it with IGNORE 1 LINES)
+	Header bool
+}
+
+// DefaultOutfileFormat returns MySQL's default tab-separated INTO OUTFILE format
+func DefaultOutfileFormat() OutfileFormat {
+	return OutfileFormat{
+		FieldsTerminatedBy: "\t",
+		FieldsEscapedBy:    '\\',
+		LinesTerminatedBy:  "\n",
+	}
+}
+
+// CSVOutfileFormat returns an RFC 4180 style CSV format with a header line.
+// Embedded quotes are doubled instead of escaped.
+func CSVOutfileFormat() OutfileFormat {
+	return OutfileFormat{
+		FieldsTerminatedBy: ",",
+		FieldsEnclosedBy:   '"',
+		OptionallyEnclosed: true,
+		LinesTerminatedBy:  "\n",
+		Header:             true,
+	}
+}
+
+// Clause returns the FIELDS and LINES clause that reads or writes this format in SQL
+func (f OutfileFormat) Clause() string {
+	var b strings.Builder
+	b.WriteString("FIELDS TERMINATED BY '")
+	b.WriteString(escapeSQLString([]byte(f.FieldsTerminatedBy)))
+	b.WriteString("'")
+	if f.FieldsEnclosedBy != 0 {
+		if f.OptionallyEnclosed {
+			b.WriteString(" OPTIONALLY")
+		}
+		b.WriteString(" ENCLOSED BY '")
+		b.WriteString(escapeSQLString([]byte{f.FieldsEnclosedBy}))
+		b.WriteString("'")
+	}
+	b.WriteString(" ESCAPED BY '")
+	if f.FieldsEscapedBy != 0 {
+		b.WriteString(escapeSQLString([]byte{f.FieldsEscapedBy}))
+	}
+	b.WriteString("' LINES")
+	if f.LinesStartingBy != "" {
+		b.WriteString(" STARTING BY '")
+		b.WriteString(escapeSQLString([]byte(f.LinesStartingBy)))
+		b.WriteString("'")
+	}
+	b.WriteString(" TERMINATED BY '")
+	b.WriteString(escapeSQLString([]byte(f.LinesTerminatedBy)))
+	b.WriteString("'")
+	if f.Header {
+		b.WriteString(" IGNORE 1 LINES")
+	}
+	return b.String()
+}
+
+func (f OutfileFormat) validate() error {
+	if f.FieldsTerminatedBy == "" {
+		return fmt.Errorf("fields terminator cannot be empty")
+	}
+	if f.LinesTerminatedBy == "" {
+		return fmt.Errorf("lines terminator cannot be empty")
+	}
+	return nil
+}
+
+// OutfileTransformer encrypts or decrypts named columns of CSV and INTO OUTFILE files.
+// Encrypted values are written as EncryptString hex, so a file loaded with LOAD DATA INFILE
+// can be decrypted with AES_DECRYPT(UNHEX(col), key). NULL values and empty strings are
+// copied unchanged.
+type OutfileTransformer struct {
+	aes     *MySQLAES
+	mode    TransformMode
+	key     string
+	format  OutfileFormat
+	fields  []string
+	columns []string
+}
+
+// NewOutfileTransformer creates an OutfileTransformer. fields names the fields of each line in
+// order and may be nil when the format has a header line. columns lists the fields to transform.
+func NewOutfileTransformer(m *MySQLAES, mode TransformMode, key string, format OutfileFormat, fields, columns []string) *OutfileTransformer {
+	return &OutfileTransformer{
+		aes:     m,
+		mode:    mode,
+		key:     key,
+		format:  format,
+		fields:  fields,
+		columns: columns,
+	}
+}
+
+// outfileField is a single decoded field value
+type outfileField struct {
+	value    []byte
+	null     bool
+	enclosed bool
+}
+
+// Transform reads records from r and writes the transformed records to w, one record at a time
+func (t *OutfileTransformer) Transform(r io.Reader, w io.Writer) error {
+	if err := t.format.validate(); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	bw := bufio.NewWriterSize(w, 64*1024)
+
+	fields := t.fields
+	var transform []bool
+	// Errors give the record number; an enclosed field can span several physical lines
+	for n := 1; ; n++ {
+		record, err := t.readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+
+		if n == 1 && t.format.Header {
+			if fields == nil {
+				for _, f := range record {
+					fields = append(fields, string(f.value))
+				}
+			}
+			if err := t.writeRecord(bw, record, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if transform == nil {
+			if transform, err = t.resolveColumns(fields); err != nil {
+				return err
+			}
+		}
+
+		changed := make([]bool, len(record))
+		for i := range record {
+			if i >= len(transform) || !transform[i] || record[i].null || len(record[i].value) == 0 {
+				continue
+			}
+			var result string
+			if t.mode == TransformEncrypt {
+				result, err = t.aes.EncryptString(string(record[i].value), t.key)
+			} else {
+				result, err = t.aes.DecryptString(string(record[i].value), t.key)
+			}
+			if err != nil {
+				return fmt.Errorf("record %d column %s: %w", n, fields[i], err)
+			}
+			record[i].value = []byte(result)
+			changed[i] = true
+		}
+		if err := t.writeRecord(bw, record, changed); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// resolveColumns maps the configured column names to field positions
+func (t *OutfileTransformer) resolveColumns(fields []string) ([]bool, error) {
+	if fields == nil {
+		return nil, fmt.Errorf("field names are required when the format has no header")
+	}
+	transform := make([]bool, len(fields))
+	for _, column := range t.columns {
+		found := false
+		for i, name := range fields {
+			if strings.EqualFold(name, column) {
+				transform[i] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column %q not found in fields", column)
+		}
+	}
+	return transform, nil
+}
+
+// readRecord reads one line following LOAD DATA INFILE parsing rules.
+// It returns io.EOF when no further record is available.
+func (t *OutfileTransformer) readRecord(br *bufio.Reader) ([]outfileField, error) {
+	f := t.format
+	if _, err := br.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	if f.LinesStartingBy != "" {
+		// Everything up to the prefix is skipped, as LOAD DATA does
+		for !hasPrefixAt(br, f.LinesStartingBy) {
+			if _, err := br.ReadByte(); err != nil {
+				return nil, io.EOF
+			}
+		}
+		br.Discard(len(f.LinesStartingBy))
+	}
+
+	var record []outfileField
+	for {
+		field, end, err := t.readField(br)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, field)
+		if end {
+			return record, nil
+		}
+	}
+}
+
+// readField reads one field and reports whether it ended the line
+func (t *OutfileTransformer) readField(br *bufio.Reader) (outfileField, bool, error) {
+	f := t.format
+	var field outfileField
+	var buf bytes.Buffer
+
+	if c, err := br.Peek(1); err == nil && f.FieldsEnclosedBy != 0 && c[0] == f.FieldsEnclosedBy {
+		br.Discard(1)
+		field.enclosed = true
+		for {
+			c, err := br.ReadByte()
+			if err != nil {
+				return field, false, fmt.Errorf("unterminated enclosed field")
+			}
+			if f.FieldsEscapedBy != 0 && c == f.FieldsEscapedBy {
+				e, err := br.ReadByte()
+				if err != nil {
+					return field, false, fmt.Errorf("unterminated escape sequence")
+				}
+				buf.Write(unescapeOutfileChar(e))
+				continue
+			}
+			if c == f.FieldsEnclosedBy {
+				// A quote only closes the field when followed by a terminator
+				if hasPrefixAt(br, f.FieldsTerminatedBy) {
+					br.Discard(len(f.FieldsTerminatedBy))
+					field.value = buf.Bytes()
+					return field, false, nil
+				}
+				if hasPrefixAt(br, f.LinesTerminatedBy) {
+					br.Discard(len(f.LinesTerminatedBy))
+					field.value = buf.Bytes()
+					return field, true, nil
+				}
+				if _, err := br.Peek(1); err == io.EOF {
+					field.value = buf.Bytes()
+					return field, true, nil
+				}
+				if next, _ := br.Peek(1); next[0] == f.FieldsEnclosedBy {
+					br.Discard(1)
+				}
+			}
+			buf.WriteByte(c)
+		}
+	}
+
+	escapedN := false
+	for {
+		if hasPrefixAt(br, f.FieldsTerminatedBy) {
+			br.Discard(len(f.FieldsTerminatedBy))
+			return finishUnenclosed(field, buf.Bytes(), escapedN, f), false, nil
+		}
+		if hasPrefixAt(br, f.LinesTerminatedBy) {
+			br.Discard(len(f.LinesTerminatedBy))
+			return finishUnenclosed(field, buf.Bytes(), escapedN, f), true, nil
+		}
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return finishUnenclosed(field, buf.Bytes(), escapedN, f), true, nil
+		}
+		if err != nil {
+			return field, false, err
+		}
+		if f.FieldsEscapedBy != 0 && c == f.FieldsEscapedBy {
+			e, err := br.ReadByte()
+			if err != nil {
+				return field, false, fmt.Errorf("unterminated escape sequence")
+			}
+			if e == 'N' && buf.Len() == 0 {
+				escapedN = true
+			}
+			buf.Write(unescapeOutfileChar(e))
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+// finishUnenclosed detects the \N and NULL markers of an unenclosed field
+func finishUnenclosed(field outfileField, value []byte, escapedN bool, f OutfileFormat) outfileField {
+	switch {
+	case escapedN && len(value) == 1:
+		field.null = true
+	case f.FieldsEscapedBy == 0 && string(value) == "NULL":
+		field.null = true
+	default:
+		field.value = value
+	}
+	return field
+}
+
+// writeRecord writes one line following SELECT ... INTO OUTFILE escaping rules
+func (t *OutfileTransformer) writeRecord(w *bufio.Writer, record []outfileField, changed []bool) error {
+	f := t.format
+	w.WriteString(f.LinesStartingBy)
+	for i, field := range record {
+		if i > 0 {
+			w.WriteString(f.FieldsTerminatedBy)
+		}
+		if field.null {
+			if f.FieldsEscapedBy != 0 {
+				w.WriteByte(f.FieldsEscapedBy)
+				w.WriteByte('N')
+			} else {
+				w.WriteString("NULL")
+			}
+			continue
+		}
+
+		enclose := f.FieldsEnclosedBy != 0 &&
+			(!f.OptionallyEnclosed || field.enclosed || (changed != nil && changed[i]))
+		if enclose {
+			w.WriteByte(f.FieldsEnclosedBy)
+		}
+		for _, c := range field.value {
+			switch {
+			case f.FieldsEscapedBy != 0 && c == 0:
+				w.WriteByte(f.FieldsEscapedBy)
+				w.WriteByte('0')
+				continue
+			case f.FieldsEscapedBy != 0 && needsOutfileEscape(c, f, enclose):
+				w.WriteByte(f.FieldsEscapedBy)
+			case f.FieldsEscapedBy == 0 && enclose && c == f.FieldsEnclosedBy:
+				w.WriteByte(c)
+			}
+			w.WriteByte(c)
+		}
+		if enclose {
+			w.WriteByte(f.FieldsEnclosedBy)
+		}
+	}
+	_, err := w.WriteString(f.LinesTerminatedBy)
+	return err
+}
+
+// needsOutfileEscape reports whether MySQL prefixes c with the escape character on output
+func needsOutfileEscape(c byte, f OutfileFormat, enclosed bool) bool {
+	if c == f.FieldsEscapedBy || (f.FieldsEnclosedBy != 0 && c == f.FieldsEnclosedBy) {
+		return true
+	}
+	if !enclosed {
+		return c == f.FieldsTerminatedBy[0] || c == f.LinesTerminatedBy[0]
+	}
+	return false
+}
+
+// unescapeOutfileChar maps the character after the escape character to its value
+func unescapeOutfileChar(c byte) []byte {
+	switch c {
+	case '0':
+		return []byte{0}
+	case 'b':
+		return []byte{'\b'}
+	case 'n':
+		return []byte{'\n'}
+	case 'r':
+		return []byte{'\r'}
+	case 't':
+		return []byte{'\t'}
+	case 'Z':
+		return []byte{0x1a}
+	default:
+		return []byte{c}
+	}
+}
+
+// hasPrefixAt reports whether the buffered reader continues with prefix
+func hasPrefixAt(br *bufio.Reader, prefix string) bool {
+	b, err := br.Peek(len(prefix))
+	return err == nil && string(b) == prefix
+}
@@ -0,0 +1,144 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestOutfileTransformer_RoundTrip(t *testing.T) {
+	aes := New()
+	key := "exportkey"
+
+	testCases := []struct {
+		name   string
+		format OutfileFormat
+		fields []string
+		input  string
+	}{
+		{
+			"default tab separated",
+			DefaultOutfileFormat(),
+			[]string{"id", "email", "note"},
+			"1\talice@example.com\tplain\n2\t\\N\ttab\\\there\n3\tline\\\nbreak\\\\x\t\n",
+		},
+		{
+			"csv with header",
+			CSVOutfileFormat(),
+			nil,
+			"id,email,note\n1,\"bob, \"\"the builder\"\"\",x\n2,NULL,\"y\"\n",
+		},
+		{
+			"enclosed and escaped",
+			OutfileFormat{
+				FieldsTerminatedBy: ",",
+				FieldsEnclosedBy:   '"',
+				FieldsEscapedBy:    '\\',
+				LinesTerminatedBy:  "\r\n",
+			},
+			[]string{"id", "email", "note"},
+			"\"1\",\"carol \\\"c\\\" \\0\",\"z\"\r\n\"2\",\\N,\"\"\r\n",
+		},
+		{
+			"lines starting by",
+			OutfileFormat{
+				FieldsTerminatedBy: "|",
+				FieldsEscapedBy:    '\\',
+				LinesStartingBy:    "xxx",
+				LinesTerminatedBy:  "\n",
+			},
+			[]string{"id", "email", "note"},
+			"xxx1|dave@example.com|\\|pipe\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var encrypted, decrypted bytes.Buffer
+			enc := NewOutfileTransformer(aes, TransformEncrypt, key, tc.format, tc.fields, []string{"email"})
+			if err := enc.Transform(strings.NewReader(tc.input), &encrypted); err != nil {
+				t.Fatalf("Encrypt transform failed: %v", err)
+			}
+			if strings.Contains(encrypted.String(), "@example.com") {
+				t.Errorf("Expected email column to be encrypted: %q", encrypted.String())
+			}
+
+			dec := NewOutfileTransformer(aes, TransformDecrypt, key, tc.format, tc.fields, []string{"email"})
+			if err := dec.Transform(&encrypted, &decrypted); err != nil {
+				t.Fatalf("Decrypt transform failed: %v", err)
+			}
+			if decrypted.String() != tc.input {
+				t.Errorf("Round-trip mismatch: expected %q, got %q", tc.input, decrypted.String())
+			}
+		})
+	}
+}
+
+func TestOutfileTransformer_HexOutput(t *testing.T) {
+	aes := New()
+	key := "exportkey"
+	input := "1\tsecret\n"
+
+	var out bytes.Buffer
+	tr := NewOutfileTransformer(aes, TransformEncrypt, key, DefaultOutfileFormat(), []string{"id", "v"}, []string{"v"})
+	if err := tr.Transform(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Transform failed: %v", err)
+	}
+
+	// The value must load into a column that AES_DECRYPT(UNHEX(v), key) can read
+	expected, _ := aes.EncryptString("secret", key)
+	if out.String() != "1\t"+expected+"\n" {
+		t.Errorf("Expected %q, got %q", "1\t"+expected+"\n", out.String())
+	}
+}
+
+func TestOutfileTransformer_Errors(t *testing.T) {
+	aes := New()
+
+	_, err := transformOutfile(aes, DefaultOutfileFormat(), nil, "1\tx\n")
+	if err == nil {
+		t.Error("Expected error for missing field names")
+	}
+
+	_, err = transformOutfile(aes, DefaultOutfileFormat(), []string{"id", "other"}, "1\tx\n")
+	if err == nil {
+		t.Error("Expected error for unknown column")
+	}
+
+	_, err = transformOutfile(aes, CSVOutfileFormat(), nil, "id,v\n1,\"unterminated\n")
+	if err == nil {
+		t.Error("Expected error for unterminated enclosed field")
+	}
+
+	// The third record starts on the fourth line
+	_, err = transformOutfile(aes, CSVOutfileFormat(), nil, "id,v\n\"1\n1\",\n2,zz\n")
+	if err == nil || !strings.HasPrefix(err.Error(), "record 3 column v:") {
+		t.Errorf("Expected error for record 3, got %v", err)
+	}
+}
+
+func TestOutfileFormat_Clause(t *testing.T) {
+	testCases := []struct {
+		name     string
+		format   OutfileFormat
+		expected string
+	}{
+		{"default", DefaultOutfileFormat(), `FIELDS TERMINATED BY '	' ESCAPED BY '\\' LINES TERMINATED BY '\n'`},
+		{"csv", CSVOutfileFormat(), `FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\n' IGNORE 1 LINES`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.format.Clause(); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func transformOutfile(aes *MySQLAES, format OutfileFormat, fields []string, input string) (string, error) {
+	var out bytes.Buffer
+	tr := NewOutfileTransformer(aes, TransformDecrypt, "key", format, fields, []string{"v"})
+	err := tr.Transform(strings.NewReader(input), &out)
+	return out.String(), err
+}
@@ -0,0 +1,217 @@
+package mysql_aes
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Padding pads plaintext to whole cipher blocks before encryption and removes the padding
+// after decryption
+type Padding interface {
+	// Name returns the scheme's name as accepted by ParsePadding
+	Name() string
+	// Pad returns data extended to a multiple of blockSize
+	Pad(data []byte, blockSize int) ([]byte, error)
+	// Unpad returns data without its padding, or an error if the padding is invalid
+	Unpad(data []byte, blockSize int) ([]byte, error)
+}
+
+var (
+	// PKCS7Padding appends n bytes of value n, as MySQL, OpenSSL and most libraries do
+	PKCS7Padding Padding = pkcs7Padding{}
+	// ZeroPadding appends zero bytes up to the block boundary, as PHP's mcrypt did. Unpad
+	// strips all trailing zeros, so plaintexts ending in zero bytes do not round-trip.
+	ZeroPadding Padding = zeroPadding{}
+	// NoPadding requires the plaintext to be a whole number of blocks
+	NoPadding Padding = noPadding{}
+	// ISO10126Padding appends random bytes and a final byte holding the padding length
+	ISO10126Padding Padding = iso10126Padding{}
+	// ANSIX923Padding appends zero bytes and a final byte holding the padding length
+	ANSIX923Padding Padding = ansiX923Padding{}
+)
+
+// Paddings lists the schemes in the order DecryptDetect tries them
+var Paddings = []Padding{PKCS7Padding, ANSIX923Padding, ISO10126Padding, ZeroPadding, NoPadding}
+
+// ParsePadding returns the scheme with the given name: pkcs7, zero, none, iso10126 or
+// ansix923
+func ParsePadding(name string) (Padding, error) {
+	for _, p := range Paddings {
+		if strings.EqualFold(name, p.Name()) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown padding %q", name)
+}
+
+// padLength returns the padding length recorded in the last byte, checking that it lies
+// within the last block
+func padLength(data []byte, blockSize int) (int, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return 0, errInvalidPadding
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize {
+		return 0, errInvalidPadding
+	}
+	return n, nil
+}
+
+type pkcs7Padding struct{}
+
+func (pkcs7Padding) Name() string { return "pkcs7" }
+
+func (pkcs7Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	n := blockSize - len(data)%blockSize
+	out := append(make([]byte, 0, len(data)+n), data...)
+	for i := 0; i < n; i++ {
+		out = append(out, byte(n))
+	}
+	return out, nil
+}
+
+func (pkcs7Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	n, err := padLength(data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, errInvalidPadding
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+type zeroPadding struct{}
+
+func (zeroPadding) Name() string { return "zero" }
+
+func (zeroPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	n := (blockSize - len(data)%blockSize) % blockSize
+	return append(append(make([]byte, 0, len(data)+n), data...), make([]byte, n)...), nil
+}
+
+func (zeroPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data)%blockSize != 0 {
+		return nil, errInvalidPadding
+	}
+	end := len(data)
+	for end > 0 && data[end-1] == 0 {
+		end--
+	}
+	return data[:end], nil
+}
+
+type noPadding struct{}
+
+func (noPadding) Name() string { return "none" }
+
+func (noPadding) Pad(data []byte, blockSize int) ([]byte, error) {
+	if len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("data length must be a multiple of %d bytes without padding", blockSize)
+	}
+	return append([]byte{}, data...), nil
+}
+
+func (noPadding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data)%blockSize != 0 {
+		return nil, errInvalidPadding
+	}
+	return data, nil
+}
+
+type iso10126Padding struct{}
+
+func (iso10126Padding) Name() string { return "iso10126" }
+
+func (iso10126Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	n := blockSize - len(data)%blockSize
+	pad := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, pad[:n-1]); err != nil {
+		return nil, fmt.Errorf("failed to generate padding: %w", err)
+	}
+	pad[n-1] = byte(n)
+	return append(append(make([]byte, 0, len(data)+n), data...), pad...), nil
+}
+
+func (iso10126Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	n, err := padLength(data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	return data[:len(data)-n], nil
+}
+
+type ansiX923Padding struct{}
+
+func (ansiX923Padding) Name() string { return "ansix923" }
+
+func (ansiX923Padding) Pad(data []byte, blockSize int) ([]byte, error) {
+	n := blockSize - len(data)%blockSize
+	pad := make([]byte, n)
+	pad[n-1] = byte(n)
+	return append(append(make([]byte, 0, len(data)+n), data...), pad...), nil
+}
+
+func (ansiX923Padding) Unpad(data []byte, blockSize int) ([]byte, error) {
+	n, err := padLength(data, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range data[len(data)-n : len(data)-1] {
+		if b != 0 {
+			return nil, errInvalidPadding
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// NewWithPadding creates a MySQLAES instance whose Encrypt and Decrypt use the given padding
+// instead of PKCS7, for clients that call themselves MySQL compatible but pad differently.
+// Values encrypted with another padding cannot be decrypted by MySQL's AES_DECRYPT.
+func NewWithPadding(padding Padding) *MySQLAES {
+	return &MySQLAES{padding: padding}
+}
+
+// WithPadding returns a copy of m that uses the given padding, which combines a padding with
+// a profile: NewWithProfile(ProfileMariaDB).WithPadding(ZeroPadding)
+func (m *MySQLAES) WithPadding(padding Padding) *MySQLAES {
+	c := *m
+	c.padding = padding
+	return &c
+}
+
+// Padding returns the padding used by Encrypt and Decrypt
+func (m *MySQLAES) Padding() Padding {
+	if m.padding == nil {
+		return PKCS7Padding
+	}
+	return m.padding
+}
+
+// DecryptDetect decrypts AES-ECB ciphertext like Decrypt, trying each padding in candidates
+// (Paddings when none are given) and returning the first that matches. Detection is
+// heuristic: PKCS7 data also passes as ISO 10126, and zero or no padding always matches, so
+// order candidates from the strictest scheme and restrict them to those the source may use.
+func (m *MySQLAES) DecryptDetect(ciphertext, key []byte, candidates ...Padding) ([]byte, Padding, error) {
+	if len(candidates) == 0 {
+		candidates = Paddings
+	}
+	plaintext, err := m.decryptBlocks(ciphertext, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, p := range candidates {
+		// Unless it is the last resort, zero padding needs a zero byte to strip
+		if p == ZeroPadding && i < len(candidates)-1 && plaintext[len(plaintext)-1] != 0 {
+			continue
+		}
+		if unpadded, err := p.Unpad(plaintext, BlockSize); err == nil {
+			return unpadded, p, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("failed to remove padding: %w", errInvalidPadding)
+}
@@ -0,0 +1,168 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPadding_Pad(t *testing.T) {
+	data := []byte("hello")
+
+	testCases := []struct {
+		padding  Padding
+		expected string
+	}{
+		{PKCS7Padding, "68656c6c6f0b0b0b0b0b0b0b0b0b0b0b"},
+		{ZeroPadding, "68656c6c6f0000000000000000000000"},
+		{ANSIX923Padding, "68656c6c6f000000000000000000000b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.padding.Name(), func(t *testing.T) {
+			padded, err := tc.padding.Pad(data, BlockSize)
+			if err != nil {
+				t.Fatalf("Padding failed: %v", err)
+			}
+			if got := hex.EncodeToString(padded); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	padded, err := ISO10126Padding.Pad(data, BlockSize)
+	if err != nil {
+		t.Fatalf("Padding failed: %v", err)
+	}
+	if len(padded) != BlockSize || padded[BlockSize-1] != 11 || !bytes.Equal(padded[:5], data) {
+		t.Errorf("Unexpected ISO 10126 padding %x", padded)
+	}
+}
+
+func TestPadding_RoundTrip(t *testing.T) {
+	key := []byte("paddingkey")
+	testCases := [][]byte{
+		[]byte("a"),
+		[]byte("exactly16bytes!!"),
+		[]byte("longer than a single block of data"),
+	}
+
+	for _, p := range Paddings {
+		t.Run(p.Name(), func(t *testing.T) {
+			aes := NewWithPadding(p)
+			for _, plaintext := range testCases {
+				if p == NoPadding && len(plaintext)%BlockSize != 0 {
+					if _, err := aes.Encrypt(plaintext, key); err == nil {
+						t.Errorf("Expected error for %d bytes without padding", len(plaintext))
+					}
+					continue
+				}
+				encrypted, err := aes.Encrypt(plaintext, key)
+				if err != nil {
+					t.Fatalf("Encryption failed: %v", err)
+				}
+				decrypted, err := aes.Decrypt(encrypted, key)
+				if err != nil {
+					t.Fatalf("Decryption failed: %v", err)
+				}
+				if !bytes.Equal(decrypted, plaintext) {
+					t.Errorf("Expected %q, got %q", plaintext, decrypted)
+				}
+			}
+		})
+	}
+}
+
+func TestPadding_UnpadErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		padding Padding
+		data    string
+	}{
+		{"pkcs7 zero length byte", PKCS7Padding, "68656c6c6f0b0b0b0b0b0b0b0b0b0b00"},
+		{"pkcs7 length over block", PKCS7Padding, "68656c6c6f0b0b0b0b0b0b0b0b0b0b11"},
+		{"pkcs7 mismatched bytes", PKCS7Padding, "68656c6c6f0b0b0b0b0b0b0b0b0b0c0b"},
+		{"ansix923 nonzero filler", ANSIX923Padding, "68656c6c6f000000000000000000010b"},
+		{"iso10126 zero length byte", ISO10126Padding, "68656c6c6f0000000000000000000000"},
+		{"none partial block", NoPadding, "68656c6c6f"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tc.data)
+			if _, err := tc.padding.Unpad(data, BlockSize); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestDecryptDetect(t *testing.T) {
+	key := []byte("detectkey")
+
+	testCases := []struct {
+		padding   Padding
+		plaintext string
+	}{
+		{PKCS7Padding, "hello"},
+		{ANSIX923Padding, "hello"},
+		{ISO10126Padding, "hello"},
+		{ZeroPadding, "hello"},
+		{NoPadding, "0123456789abcdef"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.padding.Name(), func(t *testing.T) {
+			encrypted, err := NewWithPadding(tc.padding).Encrypt([]byte(tc.plaintext), key)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			decrypted, detected, err := New().DecryptDetect(encrypted, key)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if detected != tc.padding {
+				t.Errorf("Expected padding %q, got %q", tc.padding.Name(), detected.Name())
+			}
+			if string(decrypted) != tc.plaintext {
+				t.Errorf("Expected %q, got %q", tc.plaintext, decrypted)
+			}
+		})
+	}
+}
+
+func TestDecryptDetect_Candidates(t *testing.T) {
+	key := []byte("detectkey")
+	encrypted, err := New().Encrypt([]byte("0123456789abcdef"), key)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	// Without PKCS7 among the candidates the padding block is kept as data
+	decrypted, detected, err := New().DecryptDetect(encrypted, key, ZeroPadding, NoPadding)
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if detected != NoPadding || len(decrypted) != 2*BlockSize {
+		t.Errorf("Expected no padding and 32 bytes, got %q and %d bytes", detected.Name(), len(decrypted))
+	}
+
+	if _, _, err := New().DecryptDetect(encrypted, []byte("wrongkey"), PKCS7Padding); err == nil {
+		t.Error("Expected error with the wrong key")
+	}
+}
+
+func TestParsePadding(t *testing.T) {
+	for _, p := range Paddings {
+		parsed, err := ParsePadding(p.Name())
+		if err != nil {
+			t.Fatalf("ParsePadding(%q) failed: %v", p.Name(), err)
+		}
+		if parsed != p {
+			t.Errorf("Expected %q, got %q", p.Name(), parsed.Name())
+		}
+	}
+	if _, err := ParsePadding("pkcs5"); err == nil {
+		t.Error("Expected error for unknown padding")
+	}
+}
@@ -0,0 +1,125 @@
+package mysql_aes
+
+import (
+	"crypto/aes"
+	"fmt"
+	"strings"
+)
+
+// pgType is a parsed pgcrypto raw encryption type such as aes-cbc/pad:pkcs
+type pgType struct {
+	mode    string
+	padding Padding
+}
+
+// parsePgType parses algorithm[-mode][/pad:padding]. Only AES is supported; the mode
+// defaults to cbc and the padding to pkcs, as in pgcrypto.
+func parsePgType(typ string) (pgType, error) {
+	t := pgType{mode: "cbc", padding: PKCS7Padding}
+	spec, options, _ := strings.Cut(strings.ToLower(typ), "/")
+	algorithm, mode, hasMode := strings.Cut(spec, "-")
+	if algorithm != "aes" && algorithm != "rijndael" {
+		return t, fmt.Errorf("unsupported pgcrypto algorithm %q", algorithm)
+	}
+	if hasMode {
+		if mode != "cbc" && mode != "ecb" {
+			return t, fmt.Errorf("unsupported pgcrypto mode %q", mode)
+		}
+		t.mode = mode
+	}
+	if options != "" {
+		switch options {
+		case "pad:pkcs":
+		case "pad:none":
+			t.padding = NoPadding
+		default:
+			return t, fmt.Errorf("unsupported pgcrypto option %q", options)
+		}
+	}
+	return t, nil
+}
+
+// padPgKey zero-pads the key to the smallest AES key size that holds it, as pgcrypto does
+func padPgKey(key []byte) ([]byte, error) {
+	for _, size := range []int{16, 24, 32} {
+		if len(key) <= size {
+			k := make([]byte, size)
+			copy(k, key)
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("pgcrypto key is longer than 32 bytes")
+}
+
+// PgEncrypt encrypts like pgcrypto's encrypt(data, key, typ), e.g. typ 'aes',
+// 'aes-cbc/pad:pkcs', 'aes-ecb' or 'aes-cbc/pad:none'. Unlike AES_ENCRYPT, keys are not
+// folded: they are zero-padded to 16, 24 or 32 bytes, which also selects AES-128, AES-192 or
+// AES-256, and keys longer than 32 bytes are rejected. CBC uses a zero IV.
+func PgEncrypt(data, key []byte, typ string) ([]byte, error) {
+	return PgEncryptIV(data, key, nil, typ)
+}
+
+// PgDecrypt decrypts like pgcrypto's decrypt(data, key, typ)
+func PgDecrypt(data, key []byte, typ string) ([]byte, error) {
+	return PgDecryptIV(data, key, nil, typ)
+}
+
+// PgEncryptIV encrypts like encrypt_iv(data, key, iv, typ). The IV is zero-padded or cut to
+// 16 bytes.
+func PgEncryptIV(data, key, iv []byte, typ string) ([]byte, error) {
+	return pgCrypt(data, key, iv, typ, true)
+}
+
+// PgDecryptIV decrypts like decrypt_iv(data, key, iv, typ)
+func PgDecryptIV(data, key, iv []byte, typ string) ([]byte, error) {
+	return pgCrypt(data, key, iv, typ, false)
+}
+
+// pgCrypt implements the pgcrypto raw encryption functions
+func pgCrypt(data, key, iv []byte, typ string, encrypt bool) ([]byte, error) {
+	t, err := parsePgType(typ)
+	if err != nil {
+		return nil, err
+	}
+	k, err := padPgKey(key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	ivBlock := make([]byte, BlockSize)
+	copy(ivBlock, iv)
+
+	if encrypt {
+		if data, err = t.padding.Pad(data, BlockSize); err != nil {
+			return nil, err
+		}
+	}
+	if len(data)%BlockSize != 0 {
+		return nil, fmt.Errorf("data not a multiple of block size")
+	}
+	out := cryptBlocks(block, t.mode, ivBlock, data, encrypt)
+	if encrypt {
+		return out, nil
+	}
+	unpadded, err := t.padding.Unpad(out, BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return unpadded, nil
+}
+
+// ConvertToPgcrypto decrypts a MySQL AES_ENCRYPT value and encrypts it for pgcrypto's
+// decrypt(data, pgKey, typ), for moving encrypted columns to PostgreSQL.
+//
+// No conversion is needed when the MySQL key is at most 16 bytes: the zero-padded key is
+// then the key MySQL uses, so decrypt(data, key, 'aes-ecb') reads AES_ENCRYPT values as is.
+func (m *MySQLAES) ConvertToPgcrypto(ciphertext, mysqlKey, pgKey []byte, typ string) ([]byte, error) {
+	plaintext, err := m.Decrypt(ciphertext, mysqlKey)
+	if err != nil {
+		return nil, err
+	}
+	return PgEncrypt(plaintext, pgKey, typ)
+}
@@ -0,0 +1,102 @@
+package mysql_aes
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPgEncrypt_Vectors(t *testing.T) {
+	// Produced with openssl enc using the zero-padded key and a zero IV, matching
+	// SELECT encode(encrypt('pgcrypto interop', key, typ), 'hex')
+	tests := []struct {
+		name     string
+		key      string
+		typ      string
+		expected string
+	}{
+		{"default type", "key", "aes", "c16a1733684a9716461f8f8f70b689abfef020d17142032f0d5ea576deae2486"},
+		{"explicit cbc", "key", "aes-cbc/pad:pkcs", "c16a1733684a9716461f8f8f70b689abfef020d17142032f0d5ea576deae2486"},
+		{"ecb", "key", "aes-ecb", "c16a1733684a9716461f8f8f70b689abc717530f41f320757b4aa1bfaf11c42e"},
+		{"192-bit key", "a 20 byte secret key", "aes", "5191c1d974fc3ac74aa29c3fd145af98dbb8000d479e5482dcad9848e0287be2"},
+		{"256-bit key", "a 32 byte key for pgcrypto aes!!", "aes-cbc", "936c14433edc81ac3cd3dfb6a42a1c86b7b0111f7c2cbedd4a242fde27235aa9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := PgEncrypt([]byte("pgcrypto interop"), []byte(tt.key), tt.typ)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			if got := hex.EncodeToString(encrypted); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+			decrypted, err := PgDecrypt(encrypted, []byte(tt.key), tt.typ)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if string(decrypted) != "pgcrypto interop" {
+				t.Errorf("Expected %q, got %q", "pgcrypto interop", decrypted)
+			}
+		})
+	}
+}
+
+func TestPgEncrypt_PadNone(t *testing.T) {
+	encrypted, err := PgEncryptIV([]byte("exactly 16 bytes"), []byte("key"), []byte("1234567890abcdef"), "aes-cbc/pad:none")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	if got := hex.EncodeToString(encrypted); got != "870348d42befc57a08137b7fbaca670f" {
+		t.Errorf("Expected %q, got %q", "870348d42befc57a08137b7fbaca670f", got)
+	}
+	if _, err := PgEncrypt([]byte("not aligned"), []byte("key"), "aes/pad:none"); err == nil {
+		t.Error("Expected error for unaligned data without padding")
+	}
+}
+
+func TestPgEncrypt_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		typ  string
+	}{
+		{"blowfish", "key", "bf"},
+		{"unknown mode", "key", "aes-cfb"},
+		{"unknown option", "key", "aes/pad:zero"},
+		{"long key", "a key that is longer than thirty-two bytes", "aes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PgEncrypt([]byte("x"), []byte(tt.key), tt.typ); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	encrypted, _ := PgEncrypt([]byte("x"), []byte("key"), "aes")
+	if _, err := PgDecrypt(encrypted, []byte("other"), "aes"); err == nil {
+		t.Error("Expected decryption with a wrong key to fail")
+	}
+}
+
+func TestConvertToPgcrypto(t *testing.T) {
+	aes := New()
+	mysqlValue, _ := aes.Encrypt([]byte("migrate me"), []byte("a mysql key longer than sixteen bytes"))
+
+	converted, err := aes.ConvertToPgcrypto(mysqlValue, []byte("a mysql key longer than sixteen bytes"), []byte("pg key"), "aes-cbc/pad:pkcs")
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	decrypted, err := PgDecrypt(converted, []byte("pg key"), "aes")
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if string(decrypted) != "migrate me" {
+		t.Errorf("Expected %q, got %q", "migrate me", decrypted)
+	}
+
+	// Short MySQL keys need no conversion with aes-ecb
+	mysqlValue, _ = aes.Encrypt([]byte("as is"), []byte("short key"))
+	if got, err := PgDecrypt(mysqlValue, []byte("short key"), "aes-ecb"); err != nil || string(got) != "as is" {
+		t.Errorf("Expected pgcrypto to read AES_ENCRYPT output, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,732 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenPGP packet tags (RFC 4880)
+const (
+	pgpTagSymKeySession = 3
+	pgpTagCompressed    = 8
+	pgpTagSymEncrypted  = 9
+	pgpTagLiteral       = 11
+	pgpTagSymEncryptedI = 18
+	pgpTagMDC           = 19
+)
+
+// OpenPGP algorithm IDs
+const (
+	pgpCipher3DES   = 2
+	pgpCipherCAST5  = 3
+	pgpCipherBF     = 4
+	pgpCipherAES128 = 7
+	pgpCipherAES192 = 8
+	pgpCipherAES256 = 9
+
+	pgpHashMD5  = 1
+	pgpHashSHA1 = 2
+
+	pgpCompressNone = 0
+	pgpCompressZIP  = 1
+	pgpCompressZLIB = 2
+)
+
+const (
+	// pgpMinS2KCount and pgpMaxS2KCount bound the s2k-count option
+	pgpMinS2KCount = 1024
+	pgpMaxS2KCount = 65011712
+	// pgcrypto picks a random count in this range when s2k-count is not given
+	pgpDefaultS2KCountMin = 65536
+	pgpDefaultS2KCountMax = 253952
+	pgpSaltSize           = 8
+	pgpMDCSize            = 2 + sha1.Size
+)
+
+// pgpMaxDecompressedSize bounds the decompressed contents of a message at the 1 GB limit of
+// a PostgreSQL value, which is the most pgcrypto can return
+var pgpMaxDecompressedSize int64 = 1<<30 - 1
+
+// ErrPgpWrongKey is returned when an OpenPGP message does not decrypt with the password,
+// which pgcrypto reports as "Wrong key or corrupt data"
+var ErrPgpWrongKey = errors.New("wrong key or corrupt data")
+
+// ErrPgpNotText is returned by PgpSymDecrypt for a message holding binary data, which
+// pgcrypto's pgp_sym_decrypt rejects as "Not text data"; use PgpSymDecryptBytea for those
+var ErrPgpNotText = errors.New("not text data")
+
+// pgpOptions holds the parsed pgcrypto options string
+type pgpOptions struct {
+	cipher        byte
+	s2kCipher     byte
+	compress      int
+	compressLevel int
+	s2kMode       int
+	s2kCount      int
+	s2kDigest     byte
+	disableMDC    bool
+	sessKey       bool
+	convertCRLF   bool
+	unicodeMode   bool
+}
+
+// parsePgpOptions parses a pgcrypto options string such as
+// "cipher-algo=aes256, compress-algo=1, s2k-mode=3, s2k-count=65536"
+func parsePgpOptions(options string) (pgpOptions, error) {
+	o := pgpOptions{
+		cipher:        pgpCipherAES128,
+		compressLevel: 6,
+		s2kMode:       3,
+		s2kDigest:     pgpHashSHA1,
+	}
+	for _, opt := range strings.Split(options, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return o, fmt.Errorf("invalid pgp option %q", opt)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		var err error
+		switch name {
+		case "cipher-algo":
+			o.cipher, err = parsePgpCipher(value)
+		case "s2k-cipher-algo":
+			o.s2kCipher, err = parsePgpCipher(value)
+		case "s2k-digest-algo":
+			switch value {
+			case "md5":
+				o.s2kDigest = pgpHashMD5
+			case "sha1":
+				o.s2kDigest = pgpHashSHA1
+			default:
+				err = fmt.Errorf("unsupported digest %q", value)
+			}
+		case "compress-algo":
+			o.compress, err = parsePgpInt(value, 0, 2)
+		case "compress-level":
+			o.compressLevel, err = parsePgpInt(value, 0, 9)
+		case "s2k-mode":
+			o.s2kMode, err = parsePgpInt(value, 0, 3)
+			if err == nil && o.s2kMode == 2 {
+				err = fmt.Errorf("s2k-mode must be 0, 1 or 3")
+			}
+		case "s2k-count":
+			o.s2kCount, err = parsePgpInt(value, pgpMinS2KCount, pgpMaxS2KCount)
+		case "disable-mdc", "sess-key", "convert-crlf", "unicode-mode":
+			var flag int
+			flag, err = parsePgpInt(value, 0, 1)
+			switch name {
+			case "disable-mdc":
+				o.disableMDC = flag == 1
+			case "sess-key":
+				o.sessKey = flag == 1
+			case "convert-crlf":
+				o.convertCRLF = flag == 1
+			default:
+				o.unicodeMode = flag == 1
+			}
+		default:
+			return o, fmt.Errorf("unsupported pgp option %q", name)
+		}
+		if err != nil {
+			return o, fmt.Errorf("pgp option %s: %w", name, err)
+		}
+	}
+	return o, nil
+}
+
+// parsePgpCipher maps a pgcrypto cipher name to its OpenPGP ID
+func parsePgpCipher(name string) (byte, error) {
+	switch name {
+	case "aes", "aes128":
+		return pgpCipherAES128, nil
+	case "aes192":
+		return pgpCipherAES192, nil
+	case "aes256":
+		return pgpCipherAES256, nil
+	case "3des":
+		return pgpCipher3DES, nil
+	case "bf", "cast5":
+		return 0, fmt.Errorf("cipher %q is not supported", name)
+	default:
+		return 0, fmt.Errorf("unknown cipher %q", name)
+	}
+}
+
+// parsePgpInt parses an integer option value within a range
+func parsePgpInt(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("value %q must be between %d and %d", value, min, max)
+	}
+	return n, nil
+}
+
+// pgpKeySize returns the key size of an OpenPGP cipher
+func pgpKeySize(algo byte) (int, error) {
+	switch algo {
+	case pgpCipherAES128:
+		return 16, nil
+	case pgpCipherAES192, pgpCipher3DES:
+		return 24, nil
+	case pgpCipherAES256:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("unsupported OpenPGP cipher %d", algo)
+	}
+}
+
+// pgpBlock creates the block cipher of an OpenPGP cipher ID
+func pgpBlock(algo byte, key []byte) (cipher.Block, error) {
+	if algo == pgpCipher3DES {
+		return des.NewTripleDESCipher(key)
+	}
+	return aes.NewCipher(key)
+}
+
+// pgpS2K is an OpenPGP string-to-key specifier
+type pgpS2K struct {
+	mode  byte
+	hash  byte
+	salt  []byte
+	count byte
+}
+
+// encode serializes the specifier
+func (s pgpS2K) encode() []byte {
+	out := []byte{s.mode, s.hash}
+	if s.mode != 0 {
+		out = append(out, s.salt...)
+	}
+	if s.mode == 3 {
+		out = append(out, s.count)
+	}
+	return out
+}
+
+// parsePgpS2K reads a specifier and returns the remaining bytes
+func parsePgpS2K(data []byte) (pgpS2K, []byte, error) {
+	if len(data) < 2 {
+		return pgpS2K{}, nil, fmt.Errorf("truncated S2K specifier")
+	}
+	s := pgpS2K{mode: data[0], hash: data[1]}
+	data = data[2:]
+	switch s.mode {
+	case 0:
+	case 1, 3:
+		if len(data) < pgpSaltSize {
+			return s, nil, fmt.Errorf("truncated S2K salt")
+		}
+		s.salt, data = data[:pgpSaltSize], data[pgpSaltSize:]
+		if s.mode == 3 {
+			if len(data) < 1 {
+				return s, nil, fmt.Errorf("truncated S2K count")
+			}
+			s.count, data = data[0], data[1:]
+		}
+	default:
+		return s, nil, fmt.Errorf("unsupported S2K mode %d", s.mode)
+	}
+	return s, data, nil
+}
+
+// decodeS2KCount returns the number of bytes hashed for an encoded count
+func decodeS2KCount(c byte) int {
+	return (16 + int(c&15)) << (uint(c>>4) + 6)
+}
+
+// encodeS2KCount returns the smallest encoded count hashing at least n bytes
+func encodeS2KCount(n int) byte {
+	for c := 0; c < 255; c++ {
+		if decodeS2KCount(byte(c)) >= n {
+			return byte(c)
+		}
+	}
+	return 255
+}
+
+// key derives size key bytes from the password
+func (s pgpS2K) key(password []byte, size int) ([]byte, error) {
+	var newHash func() hash.Hash
+	switch s.hash {
+	case pgpHashMD5:
+		newHash = md5.New
+	case pgpHashSHA1:
+		newHash = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported S2K digest %d", s.hash)
+	}
+
+	input := password
+	if s.mode != 0 {
+		input = append(append([]byte{}, s.salt...), password...)
+	}
+	total := len(input)
+	if s.mode == 3 && decodeS2KCount(s.count) > total {
+		total = decodeS2KCount(s.count)
+	}
+
+	var key []byte
+	for preload := 0; len(key) < size; preload++ {
+		h := newHash()
+		h.Write(make([]byte, preload))
+		for remaining := total; remaining > 0; remaining -= len(input) {
+			if remaining < len(input) {
+				h.Write(input[:remaining])
+				break
+			}
+			h.Write(input)
+		}
+		key = h.Sum(key)
+	}
+	return key[:size], nil
+}
+
+// pgpCFB runs OpenPGP CFB mode with a zero IV
+func pgpCFB(block cipher.Block, data []byte, encrypt bool) []byte {
+	out := make([]byte, len(data))
+	cfbBytes(block, make([]byte, block.BlockSize()), out, data, block.BlockSize(), encrypt)
+	return out
+}
+
+// appendPgpPacket appends a new-format packet
+func appendPgpPacket(out []byte, tag byte, body []byte) []byte {
+	out = append(out, 0xc0|tag)
+	switch n := len(body); {
+	case n < 192:
+		out = append(out, byte(n))
+	case n < 8384:
+		n -= 192
+		out = append(out, byte(n>>8)+192, byte(n))
+	default:
+		out = append(out, 0xff)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+	return append(out, body...)
+}
+
+// readPgpPacket reads one old- or new-format packet, joining partial body lengths
+func readPgpPacket(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 || data[0]&0x80 == 0 {
+		return 0, nil, nil, fmt.Errorf("invalid OpenPGP packet header")
+	}
+	header := data[0]
+	data = data[1:]
+
+	if header&0x40 == 0 {
+		tag := header >> 2 & 0xf
+		var n int
+		switch header & 3 {
+		case 0:
+			n, data = int(data[0]), data[1:]
+		case 1:
+			if len(data) < 2 {
+				return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+			}
+			n, data = int(binary.BigEndian.Uint16(data)), data[2:]
+		case 2:
+			if len(data) < 4 {
+				return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+			}
+			n, data = int(binary.BigEndian.Uint32(data)), data[4:]
+		default:
+			n = len(data)
+		}
+		if n > len(data) {
+			return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+		}
+		return tag, data[:n], data[n:], nil
+	}
+
+	tag := header & 0x3f
+	var body []byte
+	for {
+		if len(data) == 0 {
+			return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+		}
+		l1 := int(data[0])
+		var n int
+		partial := false
+		switch {
+		case l1 < 192:
+			n, data = l1, data[1:]
+		case l1 < 224:
+			if len(data) < 2 {
+				return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+			}
+			n, data = (l1-192)<<8+int(data[1])+192, data[2:]
+		case l1 == 255:
+			if len(data) < 5 {
+				return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+			}
+			n, data = int(binary.BigEndian.Uint32(data[1:])), data[5:]
+		default:
+			n, data, partial = 1<<(l1&0x1f), data[1:], true
+		}
+		if n > len(data) {
+			return 0, nil, nil, fmt.Errorf("truncated OpenPGP packet")
+		}
+		body = append(body, data[:n]...)
+		data = data[n:]
+		if !partial {
+			return tag, body, data, nil
+		}
+	}
+}
+
+// PgpSymEncrypt encrypts text like pgcrypto's pgp_sym_encrypt(data, psw, options). The
+// options string takes pgcrypto's names, e.g. "cipher-algo=aes256, compress-algo=2,
+// s2k-mode=3, s2k-count=65536"; supported ciphers are aes128, aes192, aes256 and 3des.
+func PgpSymEncrypt(data, password, options string) ([]byte, error) {
+	o, err := parsePgpOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	format := byte('t')
+	if o.unicodeMode {
+		format = 'u'
+	}
+	return pgpSymEncrypt([]byte(data), password, o, format)
+}
+
+// PgpSymEncryptBytea encrypts binary data like pgp_sym_encrypt_bytea(data, psw, options)
+func PgpSymEncryptBytea(data []byte, password, options string) ([]byte, error) {
+	o, err := parsePgpOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return pgpSymEncrypt(data, password, o, 'b')
+}
+
+// PgpSymDecrypt decrypts a message like pgp_sym_decrypt(msg, psw, options). Messages whose
+// literal data is marked binary, such as those of pgp_sym_encrypt_bytea, return ErrPgpNotText.
+func PgpSymDecrypt(msg []byte, password, options string) (string, error) {
+	format, data, err := pgpSymDecryptOptions(msg, password, options)
+	if err != nil {
+		return "", err
+	}
+	if format == 'b' {
+		return "", fmt.Errorf("%w: decrypt binary data with PgpSymDecryptBytea", ErrPgpNotText)
+	}
+	return string(data), nil
+}
+
+// PgpSymDecryptBytea decrypts a message like pgp_sym_decrypt_bytea(msg, psw, options). Of
+// the options only convert-crlf affects decryption; the others are read from the message.
+func PgpSymDecryptBytea(msg []byte, password, options string) ([]byte, error) {
+	_, data, err := pgpSymDecryptOptions(msg, password, options)
+	return data, err
+}
+
+// pgpSymDecryptOptions decrypts a message and returns its literal data format and data
+func pgpSymDecryptOptions(msg []byte, password, options string) (byte, []byte, error) {
+	o, err := parsePgpOptions(options)
+	if err != nil {
+		return 0, nil, err
+	}
+	format, data, err := pgpSymDecrypt(msg, []byte(password))
+	if err != nil {
+		return 0, nil, err
+	}
+	if o.convertCRLF {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return format, data, nil
+}
+
+// pgpSymEncrypt builds the SKESK and encrypted data packets of a symmetric message
+func pgpSymEncrypt(data []byte, password string, o pgpOptions, format byte) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+	if o.convertCRLF {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	}
+
+	s2k := pgpS2K{mode: byte(o.s2kMode), hash: o.s2kDigest}
+	if o.s2kMode != 0 {
+		s2k.salt = make([]byte, pgpSaltSize)
+		if _, err := io.ReadFull(rand.Reader, s2k.salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	if o.s2kMode == 3 {
+		count := o.s2kCount
+		if count == 0 {
+			r, err := rand.Int(rand.Reader, big.NewInt(pgpDefaultS2KCountMax-pgpDefaultS2KCountMin+1))
+			if err != nil {
+				return nil, err
+			}
+			count = pgpDefaultS2KCountMin + int(r.Int64())
+		}
+		s2k.count = encodeS2KCount(count)
+	}
+
+	s2kCipher := o.s2kCipher
+	if s2kCipher == 0 {
+		s2kCipher = o.cipher
+	}
+	s2kKeySize, err := pgpKeySize(s2kCipher)
+	if err != nil {
+		return nil, err
+	}
+	s2kKey, err := s2k.key([]byte(password), s2kKeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	skesk := append([]byte{4, s2kCipher}, s2k.encode()...)
+	sessionKey := s2kKey
+	if o.sessKey {
+		keySize, err := pgpKeySize(o.cipher)
+		if err != nil {
+			return nil, err
+		}
+		sessionKey = make([]byte, keySize)
+		if _, err := io.ReadFull(rand.Reader, sessionKey); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+		block, err := pgpBlock(s2kCipher, s2kKey)
+		if err != nil {
+			return nil, err
+		}
+		skesk = append(skesk, pgpCFB(block, append([]byte{o.cipher}, sessionKey...), true)...)
+	} else if o.cipher != s2kCipher {
+		return nil, fmt.Errorf("s2k-cipher-algo requires sess-key=1")
+	}
+
+	literal := []byte{format, 0}
+	literal = binary.BigEndian.AppendUint32(literal, uint32(time.Now().Unix()))
+	packets := appendPgpPacket(nil, pgpTagLiteral, append(literal, data...))
+	if o.compress != pgpCompressNone {
+		compressed, err := pgpCompress(packets, o.compress, o.compressLevel)
+		if err != nil {
+			return nil, err
+		}
+		packets = appendPgpPacket(nil, pgpTagCompressed, append([]byte{byte(o.compress)}, compressed...))
+	}
+
+	block, err := pgpBlock(o.cipher, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+	prefix := make([]byte, bs+2)
+	if _, err := io.ReadFull(rand.Reader, prefix[:bs]); err != nil {
+		return nil, fmt.Errorf("failed to generate prefix: %w", err)
+	}
+	copy(prefix[bs:], prefix[bs-2:bs])
+
+	out := appendPgpPacket(nil, pgpTagSymKeySession, skesk)
+	if o.disableMDC {
+		// The old packet type resynchronizes CFB after the prefix
+		encPrefix := pgpCFB(block, prefix, true)
+		body := make([]byte, len(packets))
+		cfbBytes(block, encPrefix[2:], body, packets, bs, true)
+		return appendPgpPacket(out, pgpTagSymEncrypted, append(encPrefix, body...)), nil
+	}
+
+	plain := append(append(prefix, packets...), 0xc0|pgpTagMDC, sha1.Size)
+	sum := sha1.Sum(plain)
+	plain = append(plain, sum[:]...)
+	return appendPgpPacket(out, pgpTagSymEncryptedI, append([]byte{1}, pgpCFB(block, plain, true)...)), nil
+}
+
+// pgpSymDecrypt decrypts a symmetric message and returns the literal data format and data
+func pgpSymDecrypt(msg, password []byte) (byte, []byte, error) {
+	var skesk []byte
+	for len(msg) > 0 {
+		tag, body, rest, err := readPgpPacket(msg)
+		if err != nil {
+			return 0, nil, err
+		}
+		msg = rest
+		switch tag {
+		case pgpTagSymKeySession:
+			if skesk == nil {
+				skesk = body
+			}
+		case pgpTagSymEncrypted, pgpTagSymEncryptedI:
+			if skesk == nil {
+				return 0, nil, fmt.Errorf("no symmetric key packet before the encrypted data")
+			}
+			algo, key, err := pgpSessionKey(skesk, password)
+			if err != nil {
+				return 0, nil, err
+			}
+			packets, err := pgpDecryptData(tag, body, algo, key)
+			if err != nil {
+				return 0, nil, err
+			}
+			return pgpLiteralData(packets, 0)
+		default:
+			return 0, nil, fmt.Errorf("unexpected OpenPGP packet %d", tag)
+		}
+	}
+	return 0, nil, fmt.Errorf("no encrypted data in OpenPGP message")
+}
+
+// pgpSessionKey derives or decrypts the session key of a SKESK packet
+func pgpSessionKey(skesk, password []byte) (byte, []byte, error) {
+	if len(skesk) < 2 || skesk[0] != 4 {
+		return 0, nil, fmt.Errorf("unsupported symmetric key packet")
+	}
+	algo := skesk[1]
+	s2k, encrypted, err := parsePgpS2K(skesk[2:])
+	if err != nil {
+		return 0, nil, err
+	}
+	keySize, err := pgpKeySize(algo)
+	if err != nil {
+		return 0, nil, err
+	}
+	key, err := s2k.key(password, keySize)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(encrypted) == 0 {
+		return algo, key, nil
+	}
+
+	block, err := pgpBlock(algo, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	session := pgpCFB(block, encrypted, false)
+	sessionSize, err := pgpKeySize(session[0])
+	if err != nil || sessionSize != len(session)-1 {
+		return 0, nil, ErrPgpWrongKey
+	}
+	return session[0], session[1:], nil
+}
+
+// pgpDecryptData decrypts a SED or SEIPD packet body, verifying the prefix and MDC
+func pgpDecryptData(tag byte, body []byte, algo byte, key []byte) ([]byte, error) {
+	block, err := pgpBlock(algo, key)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+
+	if tag == pgpTagSymEncrypted {
+		if len(body) < bs+2 {
+			return nil, fmt.Errorf("truncated encrypted data")
+		}
+		prefix := pgpCFB(block, body[:bs+2], false)
+		if !bytes.Equal(prefix[bs-2:bs], prefix[bs:]) {
+			return nil, ErrPgpWrongKey
+		}
+		out := make([]byte, len(body)-bs-2)
+		cfbBytes(block, body[2:bs+2], out, body[bs+2:], bs, false)
+		return out, nil
+	}
+
+	if len(body) < 1+bs+2+pgpMDCSize || body[0] != 1 {
+		return nil, fmt.Errorf("unsupported encrypted data packet")
+	}
+	plain := pgpCFB(block, body[1:], false)
+	if !bytes.Equal(plain[bs-2:bs], plain[bs:bs+2]) {
+		return nil, ErrPgpWrongKey
+	}
+	mdcStart := len(plain) - pgpMDCSize
+	sum := sha1.Sum(plain[:mdcStart+2])
+	if plain[mdcStart] != 0xc0|pgpTagMDC || plain[mdcStart+1] != sha1.Size || !bytes.Equal(sum[:], plain[mdcStart+2:]) {
+		return nil, ErrPgpWrongKey
+	}
+	return plain[bs+2 : mdcStart], nil
+}
+
+// pgpLiteralData unwraps compressed packets and returns the literal packet's format and data
+func pgpLiteralData(packets []byte, depth int) (byte, []byte, error) {
+	tag, body, _, err := readPgpPacket(packets)
+	if err != nil {
+		return 0, nil, err
+	}
+	switch tag {
+	case pgpTagLiteral:
+		if len(body) < 2 || len(body) < 2+int(body[1])+4 {
+			return 0, nil, fmt.Errorf("truncated literal data packet")
+		}
+		return body[0], body[2+int(body[1])+4:], nil
+	case pgpTagCompressed:
+		if depth > 0 || len(body) < 1 {
+			return 0, nil, fmt.Errorf("invalid compressed data packet")
+		}
+		inner, err := pgpDecompress(body[1:], int(body[0]))
+		if err != nil {
+			return 0, nil, err
+		}
+		return pgpLiteralData(inner, depth+1)
+	default:
+		return 0, nil, fmt.Errorf("unexpected OpenPGP packet %d in encrypted data", tag)
+	}
+}
+
+// pgpCompress compresses with ZIP (raw deflate) or ZLIB
+func pgpCompress(data []byte, algo, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	if algo == pgpCompressZIP {
+		w, err = flate.NewWriter(&buf, level)
+	} else {
+		w, err = zlib.NewWriterLevel(&buf, level)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pgpDecompress reverses pgpCompress
+func pgpDecompress(data []byte, algo int) ([]byte, error) {
+	var r io.Reader
+	switch algo {
+	case pgpCompressNone:
+		return data, nil
+	case pgpCompressZIP:
+		r = flate.NewReader(bytes.NewReader(data))
+	case pgpCompressZLIB:
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid compressed data: %w", err)
+		}
+		r = zr
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %d", algo)
+	}
+	out, err := io.ReadAll(io.LimitReader(r, pgpMaxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid compressed data: %w", err)
+	}
+	if int64(len(out)) > pgpMaxDecompressedSize {
+		return nil, fmt.Errorf("compressed data exceeds %d bytes", pgpMaxDecompressedSize)
+	}
+	return out, nil
+}
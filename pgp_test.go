@@ -0,0 +1,186 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestPgpSymEncrypt_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		options string
+	}{
+		{"defaults", ""},
+		{"aes256 zip", "cipher-algo=aes256, compress-algo=1"},
+		{"aes192 zlib", "cipher-algo=aes192, compress-algo=2, compress-level=9"},
+		{"3des", "cipher-algo=3des"},
+		{"simple s2k", "s2k-mode=0"},
+		{"salted md5 s2k", "s2k-mode=1, s2k-digest-algo=md5"},
+		{"iterated s2k", "s2k-mode=3, s2k-count=1024"},
+		{"no mdc", "disable-mdc=1"},
+		{"session key", "sess-key=1, cipher-algo=aes256, s2k-cipher-algo=aes128"},
+		{"unicode", "unicode-mode=1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plaintext := "pgcrypto says héllo"
+			msg, err := PgpSymEncrypt(plaintext, "secret", tc.options)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			decrypted, err := PgpSymDecrypt(msg, "secret", tc.options)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if decrypted != plaintext {
+				t.Errorf("Expected %q, got %q", plaintext, decrypted)
+			}
+			if _, err := PgpSymDecrypt(msg, "wrong", ""); !errors.Is(err, ErrPgpWrongKey) {
+				t.Errorf("Expected ErrPgpWrongKey with the wrong password, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPgpSymEncryptBytea(t *testing.T) {
+	data := bytes.Repeat([]byte{0, 1, 2, 0xff}, 5000)
+	msg, err := PgpSymEncryptBytea(data, "secret", "compress-algo=2")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	decrypted, err := PgpSymDecryptBytea(msg, "secret", "")
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Error("Round-trip mismatch")
+	}
+
+	if _, err := PgpSymDecrypt(msg, "secret", ""); !errors.Is(err, ErrPgpNotText) {
+		t.Errorf("Expected ErrPgpNotText, got %v", err)
+	}
+	text, _ := PgpSymEncrypt("text", "secret", "")
+	if decrypted, err := PgpSymDecryptBytea(text, "secret", ""); err != nil || string(decrypted) != "text" {
+		t.Errorf("Expected %q, got %q, %v", "text", decrypted, err)
+	}
+}
+
+func TestPgpSymDecrypt_DecompressedSize(t *testing.T) {
+	defer func(n int64) { pgpMaxDecompressedSize = n }(pgpMaxDecompressedSize)
+	pgpMaxDecompressedSize = 1000
+
+	for _, algo := range []string{"1", "2"} {
+		msg, err := PgpSymEncryptBytea(make([]byte, 2000), "secret", "compress-algo="+algo)
+		if err != nil {
+			t.Fatalf("Encryption failed: %v", err)
+		}
+		if _, err := PgpSymDecryptBytea(msg, "secret", ""); err == nil {
+			t.Errorf("compress-algo=%s: expected error for data over the limit", algo)
+		}
+	}
+}
+
+func TestPgpSymDecrypt_GnuPG(t *testing.T) {
+	// Produced by: printf 'from gpg' | gpg --symmetric --passphrase secret ...
+	testCases := []struct {
+		name string
+		msg  string
+	}{
+		{"aes128 zip iterated", "8c0d040703023b0ae14b586ccfccffd23d01173235d5250ece2590ba0d32eb5bb7c8422bfc268ff70a78a7e4054fc031cdd61514b952e3cade529cec92a20c7696c87b2fc7adc4fbad9e13027654"},
+		{"aes256 salted md5", "8c0c0409010112fbba0de889dd33d23901b5f5605728e96ec6265902495ba5a611672a46b4797e56181efa5ec9d70b801a2291f81378bb4b1201f90ec367fe6516de08de4e70065105"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, _ := hex.DecodeString(tc.msg)
+			decrypted, err := PgpSymDecryptBytea(msg, "secret", "")
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if string(decrypted) != "from gpg" {
+				t.Errorf("Expected %q, got %q", "from gpg", decrypted)
+			}
+			// gpg marks the data binary unless run with --textmode
+			if _, err := PgpSymDecrypt(msg, "secret", ""); !errors.Is(err, ErrPgpNotText) {
+				t.Errorf("Expected ErrPgpNotText, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPgpSymDecrypt_ConvertCRLF(t *testing.T) {
+	msg, err := PgpSymEncrypt("a\nb\n", "secret", "convert-crlf=1")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	raw, err := PgpSymDecrypt(msg, "secret", "")
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if raw != "a\r\nb\r\n" {
+		t.Errorf("Expected %q, got %q", "a\r\nb\r\n", raw)
+	}
+	converted, err := PgpSymDecrypt(msg, "secret", "convert-crlf=1")
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if converted != "a\nb\n" {
+		t.Errorf("Expected %q, got %q", "a\nb\n", converted)
+	}
+}
+
+func TestPgpSymDecrypt_Tampered(t *testing.T) {
+	msg, err := PgpSymEncrypt("integrity", "secret", "")
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+	msg[len(msg)-1] ^= 1
+	if _, err := PgpSymDecrypt(msg, "secret", ""); !errors.Is(err, ErrPgpWrongKey) {
+		t.Errorf("Expected ErrPgpWrongKey, got %v", err)
+	}
+}
+
+func TestPgpOptions_Errors(t *testing.T) {
+	testCases := []string{
+		"cipher-algo=bf",
+		"cipher-algo=des",
+		"compress-algo=3",
+		"s2k-mode=2",
+		"s2k-count=10",
+		"s2k-digest-algo=sha256",
+		"unknown=1",
+		"cipher-algo",
+		"s2k-cipher-algo=aes256",
+	}
+
+	for _, options := range testCases {
+		t.Run(options, func(t *testing.T) {
+			if _, err := PgpSymEncrypt("x", "secret", options); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestS2KCount(t *testing.T) {
+	testCases := []struct {
+		count int
+		code  byte
+	}{
+		{1024, 0x00},
+		{65536, 0x60},
+		{65011712, 0xff},
+	}
+
+	for _, tc := range testCases {
+		if got := encodeS2KCount(tc.count); got != tc.code {
+			t.Errorf("Expected code %#x for %d, got %#x", tc.code, tc.count, got)
+		}
+		if got := decodeS2KCount(tc.code); got != tc.count {
+			t.Errorf("Expected count %d for %#x, got %d", tc.count, tc.code, got)
+		}
+	}
+}
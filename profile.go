@@ -0,0 +1,200 @@
+package mysql_aes
+
+import (
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Profile selects whose AES_ENCRYPT and AES_DECRYPT behavior EncryptMode and DecryptMode follow
+type Profile int
+
+const (
+	// ProfileMySQL follows MySQL 5.6.17 and later: block_encryption_mode accepts the ecb,
+	// cbc, cfb1, cfb8, cfb128 and ofb modes, IVs shorter than 16 bytes raise an error and
+	// longer ones are cut to 16 bytes
+	ProfileMySQL Profile = iota
+	// ProfileMariaDB follows MariaDB 11.2 and later: AES_ENCRYPT(str, key, iv, mode) accepts
+	// the ecb, cbc and ctr modes, and a missing or wrong-length IV gives NULL
+	ProfileMariaDB
+	// ProfileMariaDBLegacy follows MariaDB before 11.2, whose AES_ENCRYPT only takes a string
+	// and a key and always uses aes-128-ecb
+	ProfileMariaDBLegacy
+)
+
+// DefaultBlockEncryptionMode is the block_encryption_mode default of MySQL and MariaDB
+const DefaultBlockEncryptionMode = "aes-128-ecb"
+
+// ErrNullResult is returned where the database returns NULL instead of raising an error,
+// such as for a wrong key or IV in AES_DECRYPT
+var ErrNullResult = errors.New("AES function returns NULL")
+
+// String returns the profile name
+func (p Profile) String() string {
+	switch p {
+	case ProfileMySQL:
+		return "mysql"
+	case ProfileMariaDB:
+		return "mariadb"
+	case ProfileMariaDBLegacy:
+		return "mariadb-legacy"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+// ParseProfile parses a profile name as returned by Profile.String
+func ParseProfile(s string) (Profile, error) {
+	switch strings.ToLower(s) {
+	case "mysql":
+		return ProfileMySQL, nil
+	case "mariadb":
+		return ProfileMariaDB, nil
+	case "mariadb-legacy":
+		return ProfileMariaDBLegacy, nil
+	default:
+		return 0, fmt.Errorf("unknown compatibility profile %q", s)
+	}
+}
+
+// NewWithProfile creates a new MySQLAES instance following the given database's behavior.
+// New is equivalent to NewWithProfile(ProfileMySQL). All profiles agree on the default
+// aes-128-ecb mode, so Encrypt and Decrypt behave the same under every profile. Use
+// WithPadding to change the padding of a profile's instance.
+func NewWithProfile(profile Profile) *MySQLAES {
+	return &MySQLAES{profile: profile}
+}
+
+// Profile returns the compatibility profile
+func (m *MySQLAES) Profile() Profile {
+	return m.profile
+}
+
+// blockMode is a parsed block_encryption_mode value
+type blockMode struct {
+	keySize int
+	mode    string
+}
+
+// needsIV reports whether the mode uses an initialization vector
+func (b blockMode) needsIV() bool {
+	return b.mode != "ecb"
+}
+
+// padded reports whether the mode uses PKCS7 padding; the others are stream modes
+func (b blockMode) padded() bool {
+	return b.mode == "ecb" || b.mode == "cbc"
+}
+
+// parseBlockMode parses a block_encryption_mode value such as aes-256-cbc, checking that the
+// profile supports it. An empty mode is the default.
+func (m *MySQLAES) parseBlockMode(mode string) (blockMode, error) {
+	if mode == "" {
+		mode = DefaultBlockEncryptionMode
+	}
+	var b blockMode
+	var bits int
+	if _, err := fmt.Sscanf(strings.ToLower(mode), "aes-%d-%s", &bits, &b.mode); err != nil || (bits != 128 && bits != 192 && bits != 256) {
+		return b, fmt.Errorf("invalid block encryption mode %q", mode)
+	}
+	b.keySize = bits / 8
+
+	var supported []string
+	switch m.profile {
+	case ProfileMariaDB:
+		supported = []string{"ecb", "cbc", "ctr"}
+	case ProfileMariaDBLegacy:
+		if bits != 128 || b.mode != "ecb" {
+			return b, fmt.Errorf("%s only supports %s", m.profile, DefaultBlockEncryptionMode)
+		}
+		return b, nil
+	default:
+		supported = []string{"ecb", "cbc", "cfb1", "cfb8", "cfb128", "ofb"}
+	}
+	for _, s := range supported {
+		if b.mode == s {
+			return b, nil
+		}
+	}
+	return b, fmt.Errorf("%s does not support block encryption mode %q", m.profile, mode)
+}
+
+// checkIV validates the IV the way the profile does and returns the 16 bytes to use
+func (m *MySQLAES) checkIV(b blockMode, iv []byte) ([]byte, error) {
+	if m.profile == ProfileMariaDBLegacy && iv != nil {
+		return nil, fmt.Errorf("%s does not accept an initialization vector", m.profile)
+	}
+	if !b.needsIV() {
+		// Both databases ignore the IV in ECB mode
+		return nil, nil
+	}
+	switch m.profile {
+	case ProfileMariaDB:
+		if len(iv) != BlockSize {
+			return nil, fmt.Errorf("%w: initialization vector must be %d bytes", ErrNullResult, BlockSize)
+		}
+	default:
+		if iv == nil {
+			return nil, fmt.Errorf("block encryption mode aes-%d-%s requires an initialization vector", b.keySize*8, b.mode)
+		}
+		if len(iv) < BlockSize {
+			return nil, fmt.Errorf("the initialization vector must be at least %d bytes long", BlockSize)
+		}
+	}
+	return iv[:BlockSize], nil
+}
+
+// EncryptMode encrypts like AES_ENCRYPT(str, key, iv) with block_encryption_mode set to
+// mode (or MariaDB's AES_ENCRYPT(str, key, iv, mode)), following the instance's profile. An
+// empty mode is aes-128-ecb and a nil iv means the argument is omitted. Errors wrapping
+// ErrNullResult mark cases where the database returns NULL rather than failing.
+func (m *MySQLAES) EncryptMode(plaintext, key, iv []byte, mode string) ([]byte, error) {
+	return m.cryptMode(plaintext, key, iv, mode, true)
+}
+
+// DecryptMode decrypts like AES_DECRYPT(crypt_str, key, iv) under mode, following the
+// instance's profile. A wrong key or IV in a padded mode is reported as ErrNullResult.
+// The ecb and cbc modes use the instance's padding, PKCS7 unless set with WithPadding.
+func (m *MySQLAES) DecryptMode(ciphertext, key, iv []byte, mode string) ([]byte, error) {
+	return m.cryptMode(ciphertext, key, iv, mode, false)
+}
+
+// cryptMode implements EncryptMode and DecryptMode
+func (m *MySQLAES) cryptMode(data, key, iv []byte, mode string, encrypt bool) ([]byte, error) {
+	b, err := m.parseBlockMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	iv, err = m.checkIV(b, iv)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(aesKey(key, b.keySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	if !b.padded() {
+		return cryptBlocks(block, b.mode, iv, data, encrypt), nil
+	}
+	if encrypt {
+		padded, err := m.Padding().Pad(data, BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		return cryptBlocks(block, b.mode, iv, padded, true), nil
+	}
+
+	if len(data) == 0 || len(data)%BlockSize != 0 {
+		return nil, fmt.Errorf("%w: ciphertext length must be multiple of block size", ErrNullResult)
+	}
+	unpadded, err := m.Padding().Unpad(cryptBlocks(block, b.mode, iv, data, false), BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNullResult, err)
+	}
+	return unpadded, nil
+}
@@ -0,0 +1,127 @@
+package mysql_aes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	// SQLServerPassphraseV1 is the ENCRYPTBYPASSPHRASE format of SQL Server 2016 and earlier:
+	// 3DES-CBC with a key from SHA1 of the passphrase
+	SQLServerPassphraseV1 = 1
+	// SQLServerPassphraseV2 is the format of SQL Server 2017 and later: AES-256-CBC with a key
+	// from SHA256 of the passphrase
+	SQLServerPassphraseV2 = 2
+
+	// sqlServerMagic starts the decrypted payload, stored little-endian
+	sqlServerMagic = 0xbaadf00d
+	// sqlServerHeaderSize is the magic, the authenticator length and the data length
+	sqlServerHeaderSize = 8
+	// sqlServerMaxData is the largest plaintext ENCRYPTBYPASSPHRASE accepts
+	sqlServerMaxData = 8000
+)
+
+// NVarChar encodes a string as UTF-16LE, the way SQL Server stores NVARCHAR values. Use it
+// for N'...' passphrases and plaintexts; VARCHAR values are passed as their bytes.
+func NVarChar(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(out[2*i:], u)
+	}
+	return out
+}
+
+// sqlServerCipher returns the block cipher of a format version
+func sqlServerCipher(version int, passphrase []byte) (cipher.Block, error) {
+	switch version {
+	case SQLServerPassphraseV1:
+		// Two-key 3DES from the first 16 bytes of the SHA1 digest
+		sum := sha1.Sum(passphrase)
+		return des.NewTripleDESCipher(append(sum[:16:16], sum[:8]...))
+	case SQLServerPassphraseV2:
+		sum := sha256.Sum256(passphrase)
+		return aes.NewCipher(sum[:])
+	default:
+		return nil, fmt.Errorf("unsupported ENCRYPTBYPASSPHRASE version %d", version)
+	}
+}
+
+// EncryptByPassphrase encrypts like SQL Server's ENCRYPTBYPASSPHRASE(passphrase, cleartext)
+// without an authenticator. The result is a 4-byte little-endian version, a random IV and
+// the CBC encrypted payload: the 0xBAADF00D magic, two 16-bit lengths and the data.
+func EncryptByPassphrase(passphrase, plaintext []byte, version int) ([]byte, error) {
+	if len(plaintext) > sqlServerMaxData {
+		return nil, fmt.Errorf("plaintext exceeds %d bytes", sqlServerMaxData)
+	}
+	block, err := sqlServerCipher(version, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	bs := block.BlockSize()
+
+	payload := make([]byte, sqlServerHeaderSize, sqlServerHeaderSize+len(plaintext)+bs)
+	binary.LittleEndian.PutUint32(payload, sqlServerMagic)
+	binary.LittleEndian.PutUint16(payload[6:], uint16(len(plaintext)))
+	payload, err = PKCS7Padding.Pad(append(payload, plaintext...), bs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 4+bs, 4+bs+len(payload))
+	binary.LittleEndian.PutUint32(out, uint32(version))
+	if _, err := io.ReadFull(rand.Reader, out[4:]); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+	return append(out, cryptBlocks(block, "cbc", out[4:], payload, true)...), nil
+}
+
+// DecryptByPassphrase decrypts like DECRYPTBYPASSPHRASE(passphrase, ciphertext), reading the
+// format version from the value. Where SQL Server returns NULL, such as for a wrong
+// passphrase, the error wraps ErrNullResult. Values written with an authenticator are not
+// supported.
+func DecryptByPassphrase(passphrase, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 4 {
+		return nil, fmt.Errorf("%w: value too short", ErrNullResult)
+	}
+	block, err := sqlServerCipher(int(binary.LittleEndian.Uint32(ciphertext)), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNullResult, err)
+	}
+	bs := block.BlockSize()
+	data := ciphertext[4:]
+	if len(data) < 2*bs || len(data)%bs != 0 {
+		return nil, fmt.Errorf("%w: invalid ciphertext length", ErrNullResult)
+	}
+
+	payload, err := PKCS7Padding.Unpad(cryptBlocks(block, "cbc", data[:bs], data[bs:], false), bs)
+	if err != nil || len(payload) < sqlServerHeaderSize || binary.LittleEndian.Uint32(payload) != sqlServerMagic {
+		return nil, fmt.Errorf("%w: wrong passphrase", ErrNullResult)
+	}
+	if binary.LittleEndian.Uint16(payload[4:]) != 0 {
+		return nil, fmt.Errorf("values with an authenticator are not supported")
+	}
+	size := int(binary.LittleEndian.Uint16(payload[6:]))
+	if size != len(payload)-sqlServerHeaderSize {
+		return nil, fmt.Errorf("%w: data length mismatch", ErrNullResult)
+	}
+	return payload[sqlServerHeaderSize:], nil
+}
+
+// ConvertFromSQLServer decrypts an ENCRYPTBYPASSPHRASE value and encrypts it like
+// AES_ENCRYPT(str, key), for loading SQL Server extracts into MySQL
+func (m *MySQLAES) ConvertFromSQLServer(ciphertext, passphrase, key []byte) ([]byte, error) {
+	plaintext, err := DecryptByPassphrase(passphrase, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return m.encrypt(plaintext, key)
+}
@@ -0,0 +1,128 @@
+package mysql_aes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestEncryptByPassphrase_RoundTrip(t *testing.T) {
+	testCases := []struct {
+		name       string
+		version    int
+		passphrase []byte
+		plaintext  []byte
+	}{
+		{"v1 varchar", SQLServerPassphraseV1, []byte("secret"), []byte("hello")},
+		{"v2 varchar", SQLServerPassphraseV2, []byte("secret"), []byte("hello")},
+		{"v1 nvarchar", SQLServerPassphraseV1, NVarChar("sécret"), NVarChar("héllo")},
+		{"v2 nvarchar", SQLServerPassphraseV2, NVarChar("sécret"), NVarChar("héllo")},
+		{"empty", SQLServerPassphraseV2, []byte("secret"), []byte{}},
+		{"max length", SQLServerPassphraseV2, []byte("secret"), bytes.Repeat([]byte("x"), 8000)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encrypted, err := EncryptByPassphrase(tc.passphrase, tc.plaintext, tc.version)
+			if err != nil {
+				t.Fatalf("Encryption failed: %v", err)
+			}
+			if int(encrypted[0]) != tc.version {
+				t.Errorf("Expected version byte %d, got %d", tc.version, encrypted[0])
+			}
+			decrypted, err := DecryptByPassphrase(tc.passphrase, encrypted)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, tc.plaintext) {
+				t.Errorf("Expected %q, got %q", tc.plaintext, decrypted)
+			}
+		})
+	}
+}
+
+func TestDecryptByPassphrase_Vectors(t *testing.T) {
+	// Built with openssl enc from the SHA1/SHA256 passphrase keys and a fixed IV. None was
+	// produced by ENCRYPTBYPASSPHRASE, so they check the format as documented, not as
+	// SQL Server writes it.
+	testCases := []struct {
+		name       string
+		ciphertext string
+	}{
+		{"v1", "0100000000010203040506074fb431bc018c991fe7a948501011ae4c"},
+		{"v2", "02000000000102030405060708090a0b0c0d0e0f298f354fe029b5d5d7288896e7523bb4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ciphertext, _ := hex.DecodeString(tc.ciphertext)
+			decrypted, err := DecryptByPassphrase([]byte("secret"), ciphertext)
+			if err != nil {
+				t.Fatalf("Decryption failed: %v", err)
+			}
+			if string(decrypted) != "hello" {
+				t.Errorf("Expected %q, got %q", "hello", decrypted)
+			}
+		})
+	}
+}
+
+func TestDecryptByPassphrase_Errors(t *testing.T) {
+	encrypted, err := EncryptByPassphrase([]byte("secret"), []byte("hello"), SQLServerPassphraseV2)
+	if err != nil {
+		t.Fatalf("Encryption failed: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		passphrase string
+		ciphertext []byte
+	}{
+		{"wrong passphrase", "other", encrypted},
+		{"unknown version", "secret", append([]byte{3}, encrypted[1:]...)},
+		{"truncated", "secret", encrypted[:20]},
+		{"too short", "secret", []byte{2, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecryptByPassphrase([]byte(tc.passphrase), tc.ciphertext)
+			if !errors.Is(err, ErrNullResult) {
+				t.Errorf("Expected ErrNullResult, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptByPassphrase_Errors(t *testing.T) {
+	if _, err := EncryptByPassphrase([]byte("secret"), []byte("x"), 3); err == nil {
+		t.Error("Expected error for unknown version")
+	}
+	if _, err := EncryptByPassphrase([]byte("secret"), make([]byte, 8001), SQLServerPassphraseV2); err == nil {
+		t.Error("Expected error for oversized plaintext")
+	}
+}
+
+func TestConvertFromSQLServer(t *testing.T) {
+	aes := New()
+	ciphertext, _ := hex.DecodeString("02000000000102030405060708090a0b0c0d0e0f298f354fe029b5d5d7288896e7523bb4")
+
+	converted, err := aes.ConvertFromSQLServer(ciphertext, []byte("secret"), []byte("mysqlkey"))
+	if err != nil {
+		t.Fatalf("Conversion failed: %v", err)
+	}
+	decrypted, err := aes.Decrypt(converted, []byte("mysqlkey"))
+	if err != nil {
+		t.Fatalf("Decryption failed: %v", err)
+	}
+	if string(decrypted) != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", decrypted)
+	}
+}
+
+func TestNVarChar(t *testing.T) {
+	if got := hex.EncodeToString(NVarChar("a€")); got != "6100ac20" {
+		t.Errorf("Expected %q, got %q", "6100ac20", got)
+	}
+}
@@ -0,0 +1,208 @@
+package mysql_aes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+const (
+	// DefaultNgramSize is the default n-gram length in characters
+	DefaultNgramSize = 3
+	// DefaultMaxPrefix is the default longest prefix, in characters, that gets its own token
+	DefaultMaxPrefix = 16
+)
+
+// TokenIndex produces keyed n-gram and prefix tokens of a plaintext so that encrypted values
+// can be searched by prefix or substring through a side table of (row_id, token) pairs.
+//
+// The token key is derived from the data encryption key using the same key folding as
+// AES_ENCRYPT, so tokens rotate together with the data keys. Token matches can include
+// false positives; decrypt the candidate rows and check them before returning results.
+type TokenIndex struct {
+	key         []byte
+	ngram       int
+	maxPrefix   int
+	size        int
+	normalizers []Normalizer
+}
+
+// NewTokenIndex creates a TokenIndex for one column. dataKey is the key the column is
+// encrypted with (for per-user data, the DeriveUserKey result) and column separates the tokens
+// of different columns encrypted with the same key. Tokens are truncated to size bytes.
+func NewTokenIndex(dataKey, column string, ngram, maxPrefix, size int, normalizers ...Normalizer) (*TokenIndex, error) {
+	if dataKey == "" {
+		return nil, fmt.Errorf("key cannot be empty")
+	}
+	if ngram < 1 {
+		return nil, fmt.Errorf("n-gram size must be positive")
+	}
+	if maxPrefix < 0 {
+		return nil, fmt.Errorf("max prefix cannot be negative")
+	}
+	if size < MinBlindIndexSize || size > sha256.Size {
+		return nil, fmt.Errorf("token size must be between %d and %d bytes", MinBlindIndexSize, sha256.Size)
+	}
+
+	mac := hmac.New(sha256.New, aesKey([]byte(dataKey), AESKeyLen/8))
+	mac.Write([]byte("mysql-aes token index\x00" + column))
+	return &TokenIndex{
+		key:         mac.Sum(nil),
+		ngram:       ngram,
+		maxPrefix:   maxPrefix,
+		size:        size,
+		normalizers: normalizers,
+	}, nil
+}
+
+// normalize applies the configured normalizers to value
+func (ti *TokenIndex) normalize(value string) []rune {
+	for _, n := range ti.normalizers {
+		value = n(value)
+	}
+	return []rune(value)
+}
+
+// token computes one token; kind keeps prefix and n-gram tokens of the same text apart
+func (ti *TokenIndex) token(mac hash.Hash, kind byte, text []rune) string {
+	mac.Reset()
+	mac.Write([]byte{kind})
+	mac.Write([]byte(string(text)))
+	return hex.EncodeToString(mac.Sum(nil)[:ti.size])
+}
+
+// Tokens returns the distinct prefix and n-gram tokens to store for plaintext
+func (ti *TokenIndex) Tokens(plaintext string) []string {
+	text := ti.normalize(plaintext)
+	mac := hmac.New(sha256.New, ti.key)
+
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(tok string) {
+		if !seen[tok] {
+			seen[tok] = true
+			tokens = append(tokens, tok)
+		}
+	}
+	for i := 1; i <= len(text) && i <= ti.maxPrefix; i++ {
+		add(ti.token(mac, 'p', text[:i]))
+	}
+	for i := 0; i+ti.ngram <= len(text); i++ {
+		add(ti.token(mac, 'g', text[i:i+ti.ngram]))
+	}
+	return tokens
+}
+
+// SearchTokens returns the tokens a row must have to match a LIKE pattern. '%' and '_' are
+// wildcards and '\' escapes them. A pattern without a leading wildcard is searched by prefix;
+// other literal runs are searched by their n-grams. Runs shorter than the n-gram size cannot
+// be searched, and an error is returned if the pattern yields no tokens at all.
+func (ti *TokenIndex) SearchTokens(pattern string) ([]string, error) {
+	segments, anchored := splitLikePattern(pattern)
+	mac := hmac.New(sha256.New, ti.key)
+
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(tok string) {
+		if !seen[tok] {
+			seen[tok] = true
+			tokens = append(tokens, tok)
+		}
+	}
+	for i, segment := range segments {
+		text := ti.normalize(segment)
+		if i == 0 && anchored && len(text) > 0 && ti.maxPrefix > 0 {
+			n := len(text)
+			if n > ti.maxPrefix {
+				n = ti.maxPrefix
+			}
+			add(ti.token(mac, 'p', text[:n]))
+		}
+		for j := 0; j+ti.ngram <= len(text); j++ {
+			add(ti.token(mac, 'g', text[j:j+ti.ngram]))
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("pattern %q is too short to search", pattern)
+	}
+	return tokens, nil
+}
+
+// splitLikePattern splits a LIKE pattern into its literal runs and reports whether the
+// first run is anchored at the start of the value
+func splitLikePattern(pattern string) ([]string, bool) {
+	var segments []string
+	var current strings.Builder
+	anchored := true
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			current.WriteByte(pattern[i])
+		case c == '%' || c == '_':
+			if i == 0 {
+				anchored = false
+			}
+			if current.Len() > 0 {
+				segments = append(segments, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(c)
+		}
+	}
+	if current.Len() > 0 {
+		segments = append(segments, current.String())
+	}
+	return segments, anchored
+}
+
+// CreateTableSQL returns the DDL for a token side table
+func (ti *TokenIndex) CreateTableSQL(table string) string {
+	return fmt.Sprintf("CREATE TABLE %s (\n"+
+		"  `row_id` BIGINT UNSIGNED NOT NULL,\n"+
+		"  `token` CHAR(%d) CHARACTER SET ascii NOT NULL,\n"+
+		"  PRIMARY KEY (`token`, `row_id`),\n"+
+		"  KEY `idx_row_id` (`row_id`)\n"+
+		")", quoteIdentifier(table), ti.size*2)
+}
+
+// InsertSQL returns a statement and arguments that store the tokens of plaintext for a row
+func (ti *TokenIndex) InsertSQL(table string, rowID interface{}, plaintext string) (string, []interface{}) {
+	tokens := ti.Tokens(plaintext)
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	args := make([]interface{}, 0, len(tokens)*2)
+	for _, tok := range tokens {
+		args = append(args, rowID, tok)
+	}
+	values := strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tokens)), ", ")
+	return "INSERT IGNORE INTO " + quoteIdentifier(table) + " (`row_id`, `token`) VALUES " + values, args
+}
+
+// DeleteSQL returns a statement and arguments that remove all tokens of a row, to be run
+// before re-inserting tokens when the value changes
+func (ti *TokenIndex) DeleteSQL(table string, rowID interface{}) (string, []interface{}) {
+	return "DELETE FROM " + quoteIdentifier(table) + " WHERE `row_id` = ?", []interface{}{rowID}
+}
+
+// SearchSQL turns a LIKE pattern into a query returning the candidate row IDs
+func (ti *TokenIndex) SearchSQL(table, pattern string) (string, []interface{}, error) {
+	tokens, err := ti.SearchTokens(pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]interface{}, len(tokens))
+	for i, tok := range tokens {
+		args[i] = tok
+	}
+	query := "SELECT `row_id` FROM " + quoteIdentifier(table) +
+		" WHERE `token` IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(tokens)), ", ") + ")" +
+		fmt.Sprintf(" GROUP BY `row_id` HAVING COUNT(DISTINCT `token`) = %d", len(tokens))
+	return query, args, nil
+}
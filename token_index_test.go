@@ -0,0 +1,130 @@
+package mysql_aes
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestTokenIndex(t *testing.T, key string) *TokenIndex {
+	ti, err := NewTokenIndex(key, "users.email", DefaultNgramSize, DefaultMaxPrefix, 8, NormalizeNFC, NormalizeCaseFold)
+	if err != nil {
+		t.Fatalf("NewTokenIndex failed: %v", err)
+	}
+	return ti
+}
+
+func TestTokenIndex_Search(t *testing.T) {
+	ti := newTestTokenIndex(t, "data-key")
+	stored := make(map[string]bool)
+	for _, tok := range ti.Tokens("Alice.Smith@Example.com") {
+		stored[tok] = true
+	}
+
+	testCases := []struct {
+		pattern string
+		match   bool
+	}{
+		{"ali%", true},
+		{"ALICE.S%", true},
+		{"a%", true},
+		{"%smith%", true},
+		{"%example.com", true},
+		{"al%ample%", true},
+		{"bob%", false},
+		{"%jones%", false},
+		{"lic%", false}, // not a prefix
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			tokens, err := ti.SearchTokens(tc.pattern)
+			if err != nil {
+				t.Fatalf("SearchTokens failed: %v", err)
+			}
+			match := true
+			for _, tok := range tokens {
+				if !stored[tok] {
+					match = false
+				}
+			}
+			if match != tc.match {
+				t.Errorf("Expected match=%v for %q", tc.match, tc.pattern)
+			}
+		})
+	}
+}
+
+func TestTokenIndex_KeyRotation(t *testing.T) {
+	oldIndex := newTestTokenIndex(t, "old-key")
+	newIndex := newTestTokenIndex(t, "new-key")
+	if oldIndex.Tokens("alice")[0] == newIndex.Tokens("alice")[0] {
+		t.Error("Expected tokens to change with the data key")
+	}
+
+	// Keys that MySQL folds to the same AES key produce the same tokens
+	a := newTestTokenIndex(t, "0123456789abcdef")
+	b := newTestTokenIndex(t, "0123456789abcdef\x00\x00")
+	if a.Tokens("alice")[0] != b.Tokens("alice")[0] {
+		t.Error("Expected equivalent MySQL keys to produce the same tokens")
+	}
+
+	other, _ := NewTokenIndex("old-key", "users.name", DefaultNgramSize, DefaultMaxPrefix, 8)
+	if other.Tokens("alice")[0] == oldIndex.Tokens("alice")[0] {
+		t.Error("Expected tokens to differ between columns")
+	}
+}
+
+func TestTokenIndex_SQL(t *testing.T) {
+	ti := newTestTokenIndex(t, "data-key")
+
+	ddl := ti.CreateTableSQL("user_email_tokens")
+	if !strings.Contains(ddl, "CREATE TABLE `user_email_tokens`") || !strings.Contains(ddl, "CHAR(16)") {
+		t.Errorf("Unexpected DDL: %s", ddl)
+	}
+
+	query, args := ti.InsertSQL("user_email_tokens", 42, "abcd")
+	// 4 prefixes and 2 trigrams
+	if len(args) != 12 || strings.Count(query, "(?, ?)") != 6 || args[0] != 42 {
+		t.Errorf("Unexpected insert: %s %v", query, args)
+	}
+
+	query, args = ti.DeleteSQL("user_email_tokens", 42)
+	if query != "DELETE FROM `user_email_tokens` WHERE `row_id` = ?" || args[0] != 42 {
+		t.Errorf("Unexpected delete: %s %v", query, args)
+	}
+
+	query, args, err := ti.SearchSQL("user_email_tokens", "%abcd%")
+	if err != nil {
+		t.Fatalf("SearchSQL failed: %v", err)
+	}
+	if len(args) != 2 || !strings.HasSuffix(query, "HAVING COUNT(DISTINCT `token`) = 2") {
+		t.Errorf("Unexpected search: %s %v", query, args)
+	}
+
+	if _, _, err := ti.SearchSQL("user_email_tokens", "%ab%"); err == nil {
+		t.Error("Expected error for pattern shorter than the n-gram size")
+	}
+}
+
+func TestSplitLikePattern(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		segments []string
+		anchored bool
+	}{
+		{"abc%", []string{"abc"}, true},
+		{"%abc%", []string{"abc"}, false},
+		{"_abc", []string{"abc"}, false},
+		{"a_b%c", []string{"a", "b", "c"}, true},
+		{`100\%%`, []string{"100%"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			segments, anchored := splitLikePattern(tc.pattern)
+			if strings.Join(segments, "|") != strings.Join(tc.segments, "|") || anchored != tc.anchored {
+				t.Errorf("Expected %v %v, got %v %v", tc.segments, tc.anchored, segments, anchored)
+			}
+		})
+	}
+}
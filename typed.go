@@ -0,0 +1,275 @@
+package mysql_aes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// mysqlDateFormat is the string form of a DATE value
+	mysqlDateFormat = "2006-01-02"
+	// mysqlDatetimeFormat is the string form of a DATETIME value without fractional seconds
+	mysqlDatetimeFormat = "2006-01-02 15:04:05"
+	// maxFractionalDigits is the largest DATETIME fractional seconds precision
+	maxFractionalDigits = 6
+	// maxDecptForFixed is the exponent range MySQL prints doubles without an exponent for
+	maxDecptForFixed = 15
+)
+
+// EncryptInt64 encrypts an integer the way AES_ENCRYPT(123, k) does, as the string '123'
+func (m *MySQLAES) EncryptInt64(value int64, key string) (string, error) {
+	return m.EncryptString(strconv.FormatInt(value, 10), key)
+}
+
+// DecryptInt64 decrypts a value from EncryptInt64 or AES_ENCRYPT on an integer column
+func (m *MySQLAES) DecryptInt64(ciphertextHex, key string) (int64, error) {
+	s, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("decrypted value is not an integer: %w", err)
+	}
+	return value, nil
+}
+
+// EncryptBool encrypts a boolean the way MySQL does, as '1' for TRUE and '0' for FALSE
+func (m *MySQLAES) EncryptBool(value bool, key string) (string, error) {
+	if value {
+		return m.EncryptString("1", key)
+	}
+	return m.EncryptString("0", key)
+}
+
+// DecryptBool decrypts a boolean; like MySQL, any non-zero number is true
+func (m *MySQLAES) DecryptBool(ciphertextHex, key string) (bool, error) {
+	s, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return false, err
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, fmt.Errorf("decrypted value is not a boolean: %w", err)
+	}
+	return value != 0, nil
+}
+
+// EncryptFloat encrypts a DOUBLE value using MySQL's double to string conversion: the
+// shortest digits that round-trip, with an exponent only for very large or small values
+// ('0.1', '1e15', '1.5e-20')
+func (m *MySQLAES) EncryptFloat(value float64, key string) (string, error) {
+	s, err := FormatMySQLFloat(value)
+	if err != nil {
+		return "", err
+	}
+	return m.EncryptString(s, key)
+}
+
+// DecryptFloat decrypts a DOUBLE value
+func (m *MySQLAES) DecryptFloat(ciphertextHex, key string) (float64, error) {
+	s, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("decrypted value is not a number: %w", err)
+	}
+	return value, nil
+}
+
+// FormatMySQLFloat formats a double the way MySQL casts it to a string
+func FormatMySQLFloat(value float64) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("MySQL has no representation for %v", value)
+	}
+	if value == 0 {
+		return "0", nil
+	}
+
+	// Shortest round-trip digits and decimal point position, as dtoa returns them
+	e := strconv.FormatFloat(math.Abs(value), 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exp, _ := strconv.Atoi(exponent)
+	decpt := exp + 1
+
+	var b strings.Builder
+	if value < 0 {
+		b.WriteByte('-')
+	}
+	if decpt >= -maxDecptForFixed+1 && (decpt <= maxDecptForFixed || len(digits) > decpt) {
+		switch {
+		case decpt <= 0:
+			b.WriteString("0.")
+			b.WriteString(strings.Repeat("0", -decpt))
+			b.WriteString(digits)
+		case decpt >= len(digits):
+			b.WriteString(digits)
+			b.WriteString(strings.Repeat("0", decpt-len(digits)))
+		default:
+			b.WriteString(digits[:decpt])
+			b.WriteByte('.')
+			b.WriteString(digits[decpt:])
+		}
+		return b.String(), nil
+	}
+
+	b.WriteByte(digits[0])
+	if len(digits) > 1 {
+		b.WriteByte('.')
+		b.WriteString(digits[1:])
+	}
+	b.WriteByte('e')
+	b.WriteString(strconv.Itoa(exp))
+	return b.String(), nil
+}
+
+// EncryptTime encrypts a DATETIME value as 'YYYY-MM-DD HH:MM:SS' followed by fsp fractional
+// digits, matching AES_ENCRYPT(NOW(fsp), k). The fraction is rounded to fsp digits as MySQL
+// does when storing. The wall clock of t's location is used as is.
+func (m *MySQLAES) EncryptTime(t time.Time, fsp int, key string) (string, error) {
+	s, err := FormatMySQLDatetime(t, fsp)
+	if err != nil {
+		return "", err
+	}
+	return m.EncryptString(s, key)
+}
+
+// EncryptDate encrypts a DATE value as 'YYYY-MM-DD', matching AES_ENCRYPT(CURDATE(), k)
+func (m *MySQLAES) EncryptDate(t time.Time, key string) (string, error) {
+	return m.EncryptString(t.Format(mysqlDateFormat), key)
+}
+
+// DecryptTime decrypts a DATETIME or DATE value, interpreting it in loc
+func (m *MySQLAES) DecryptTime(ciphertextHex, key string, loc *time.Location) (time.Time, error) {
+	s, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	layout := mysqlDatetimeFormat
+	if len(s) == len(mysqlDateFormat) {
+		layout = mysqlDateFormat
+	} else if len(s) > len(mysqlDatetimeFormat) {
+		layout += ".999999"
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("decrypted value is not a datetime: %w", err)
+	}
+	return t, nil
+}
+
+// FormatMySQLDatetime formats t as a DATETIME string with fsp fractional digits
+func FormatMySQLDatetime(t time.Time, fsp int) (string, error) {
+	if fsp < 0 || fsp > maxFractionalDigits {
+		return "", fmt.Errorf("fractional seconds precision must be between 0 and %d", maxFractionalDigits)
+	}
+	unit := time.Duration(math.Pow10(9 - fsp))
+	t = t.Round(unit)
+	s := t.Format(mysqlDatetimeFormat)
+	if fsp > 0 {
+		s += fmt.Sprintf(".%09d", t.Nanosecond())[:fsp+1]
+	}
+	return s, nil
+}
+
+// EncryptDecimal encrypts a DECIMAL value given as a decimal string, formatted with exactly
+// scale fractional digits like a DECIMAL(M, scale) column ('5' becomes '5.00' for scale 2).
+// Extra digits are rounded half away from zero, as MySQL does.
+func (m *MySQLAES) EncryptDecimal(value string, scale int, key string) (string, error) {
+	s, err := FormatMySQLDecimal(value, scale)
+	if err != nil {
+		return "", err
+	}
+	return m.EncryptString(s, key)
+}
+
+// DecryptDecimal decrypts a DECIMAL value and returns its canonical decimal string
+func (m *MySQLAES) DecryptDecimal(ciphertextHex, key string) (string, error) {
+	s, err := m.DecryptString(ciphertextHex, key)
+	if err != nil {
+		return "", err
+	}
+	if _, _, _, err := parseDecimal(s); err != nil {
+		return "", fmt.Errorf("decrypted value is not a decimal: %w", err)
+	}
+	return s, nil
+}
+
+// FormatMySQLDecimal formats a decimal string with exactly scale fractional digits
+func FormatMySQLDecimal(value string, scale int) (string, error) {
+	if scale < 0 || scale > 30 {
+		return "", fmt.Errorf("decimal scale must be between 0 and 30")
+	}
+	neg, intPart, fracPart, err := parseDecimal(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(fracPart) > scale {
+		roundUp := fracPart[scale] >= '5'
+		fracPart = fracPart[:scale]
+		if roundUp {
+			digits := []byte(intPart + fracPart)
+			i := len(digits) - 1
+			for ; i >= 0 && digits[i] == '9'; i-- {
+				digits[i] = '0'
+			}
+			if i < 0 {
+				digits = append([]byte{'1'}, digits...)
+			} else {
+				digits[i]++
+			}
+			intPart, fracPart = string(digits[:len(digits)-scale]), string(digits[len(digits)-scale:])
+		}
+	} else {
+		fracPart += strings.Repeat("0", scale-len(fracPart))
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	if neg && strings.Trim(intPart+fracPart, "0") == "" {
+		// MySQL has no negative zero
+		neg = false
+	}
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	b.WriteString(intPart)
+	if scale > 0 {
+		b.WriteByte('.')
+		b.WriteString(fracPart)
+	}
+	return b.String(), nil
+}
+
+// parseDecimal splits a plain decimal string into its sign, integer and fraction digits
+func parseDecimal(value string) (bool, string, string, error) {
+	s := strings.TrimSpace(value)
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return false, "", "", fmt.Errorf("invalid decimal %q", value)
+	}
+	for _, part := range []string{intPart, fracPart} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return false, "", "", fmt.Errorf("invalid decimal %q", value)
+			}
+		}
+	}
+	return neg, intPart, fracPart, nil
+}
@@ -0,0 +1,171 @@
+package mysql_aes
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatMySQLFloat(t *testing.T) {
+	testCases := []struct {
+		value    float64
+		expected string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{-1.5, "-1.5"},
+		{0.1, "0.1"},
+		{1.0 / 3, "0.3333333333333333"},
+		{123456789012345, "123456789012345"},
+		{1e14, "100000000000000"},
+		{1e15, "1e15"},
+		{1.5e15, "1.5e15"},
+		{1234567890123456.7, "1234567890123456.8"},
+		{1e-5, "0.00001"},
+		{1e-15, "0.000000000000001"},
+		{1e-16, "1e-16"},
+		{-2.5e-20, "-2.5e-20"},
+		{math.MaxFloat64, "1.7976931348623157e308"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			got, err := FormatMySQLFloat(tc.value)
+			if err != nil {
+				t.Fatalf("FormatMySQLFloat failed: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	if _, err := FormatMySQLFloat(math.NaN()); err == nil {
+		t.Error("Expected error for NaN")
+	}
+}
+
+func TestFormatMySQLDatetime(t *testing.T) {
+	ts := time.Date(2024, 3, 9, 7, 5, 3, 123456789, time.UTC)
+
+	testCases := []struct {
+		fsp      int
+		expected string
+	}{
+		{0, "2024-03-09 07:05:03"},
+		{3, "2024-03-09 07:05:03.123"},
+		{6, "2024-03-09 07:05:03.123457"},
+	}
+
+	for _, tc := range testCases {
+		got, err := FormatMySQLDatetime(ts, tc.fsp)
+		if err != nil {
+			t.Fatalf("FormatMySQLDatetime failed: %v", err)
+		}
+		if got != tc.expected {
+			t.Errorf("fsp %d: expected %q, got %q", tc.fsp, tc.expected, got)
+		}
+	}
+
+	// Rounding carries into the seconds
+	got, _ := FormatMySQLDatetime(time.Date(2024, 12, 31, 23, 59, 59, 999999999, time.UTC), 0)
+	if got != "2025-01-01 00:00:00" {
+		t.Errorf("Expected rounding to carry, got %q", got)
+	}
+
+	if _, err := FormatMySQLDatetime(ts, 7); err == nil {
+		t.Error("Expected error for fsp above 6")
+	}
+}
+
+func TestFormatMySQLDecimal(t *testing.T) {
+	testCases := []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{"5", 2, "5.00"},
+		{"12.345", 2, "12.35"},
+		{"-12.345", 2, "-12.35"},
+		{"12.344", 2, "12.34"},
+		{"9.995", 2, "10.00"},
+		{"99.5", 0, "100"},
+		{"0007.10", 3, "7.100"},
+		{".5", 1, "0.5"},
+		{"-0.001", 2, "0.00"},
+		{"+3", 0, "3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := FormatMySQLDecimal(tc.value, tc.scale)
+			if err != nil {
+				t.Fatalf("FormatMySQLDecimal failed: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "abc", "1.2.3", "1e5", "-"} {
+		if _, err := FormatMySQLDecimal(invalid, 2); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestMySQLAES_TypedRoundTrip(t *testing.T) {
+	aes := New()
+	key := "typedkey"
+
+	// Typed values encrypt exactly like their MySQL string forms
+	intHex, _ := aes.EncryptInt64(-123, key)
+	strHex, _ := aes.EncryptString("-123", key)
+	if intHex != strHex {
+		t.Error("Expected EncryptInt64 to match AES_ENCRYPT of '-123'")
+	}
+	i, err := aes.DecryptInt64(intHex, key)
+	if err != nil || i != -123 {
+		t.Errorf("Expected -123, got %d, %v", i, err)
+	}
+
+	boolHex, _ := aes.EncryptBool(true, key)
+	b, err := aes.DecryptBool(boolHex, key)
+	if err != nil || !b {
+		t.Errorf("Expected true, got %v, %v", b, err)
+	}
+
+	floatHex, _ := aes.EncryptFloat(0.1, key)
+	f, err := aes.DecryptFloat(floatHex, key)
+	if err != nil || f != 0.1 {
+		t.Errorf("Expected 0.1, got %v, %v", f, err)
+	}
+
+	decHex, _ := aes.EncryptDecimal("19.999", 2, key)
+	d, err := aes.DecryptDecimal(decHex, key)
+	if err != nil || d != "20.00" {
+		t.Errorf("Expected 20.00, got %q, %v", d, err)
+	}
+
+	ts := time.Date(2024, 3, 9, 7, 5, 3, 500000000, time.UTC)
+	timeHex, _ := aes.EncryptTime(ts, 6, key)
+	got, err := aes.DecryptTime(timeHex, key, time.UTC)
+	if err != nil || !got.Equal(ts) {
+		t.Errorf("Expected %v, got %v, %v", ts, got, err)
+	}
+
+	dateHex, _ := aes.EncryptDate(ts, key)
+	got, err = aes.DecryptTime(dateHex, key, time.UTC)
+	if err != nil || !got.Equal(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected date only, got %v, %v", got, err)
+	}
+
+	textHex, _ := aes.EncryptString("not a number", key)
+	if _, err := aes.DecryptInt64(textHex, key); err == nil {
+		t.Error("Expected error decrypting text as an integer")
+	}
+	if _, err := aes.DecryptTime(textHex, key, time.UTC); err == nil {
+		t.Error("Expected error decrypting text as a datetime")
+	}
+}